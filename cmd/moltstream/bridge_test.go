@@ -0,0 +1,791 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/internal/protocol"
+	"github.com/albxllm/moltstream/internal/session"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// message is a line the bridge wrote: a response or a notification.
+type message struct {
+	ID     *int               `json:"id"`
+	Result json.RawMessage    `json:"result"`
+	Error  *protocol.RPCError `json:"error"`
+	Method string             `json:"method"`
+	Params json.RawMessage    `json:"params"`
+}
+
+// runParams holds the fields every run notification has.
+type runParams struct {
+	RunID string `json:"run_id"`
+	ID    int    `json:"id"`
+	Delta string `json:"delta"`
+}
+
+// testBridge is a bridge connected to a fake gateway, with its output
+// readable message by message.
+type testBridge struct {
+	*Bridge
+	t    *testing.T
+	msgs chan message
+}
+
+// newTestBridge starts a bridge for srv with its session in a temporary
+// directory and waits until it is connected. edit, if not nil, adjusts the
+// config first.
+func newTestBridge(t *testing.T, srv *gatewaytest.Server, edit func(*Config)) *testBridge {
+	t.Helper()
+	b := startBridge(t, func(config *Config) {
+		config.Gateway.URL = srv.URL
+		if edit != nil {
+			edit(config)
+		}
+	})
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	b.waitFor("connected")
+	return b
+}
+
+// startBridge creates a bridge with the config adjusted by edit, without
+// connecting it.
+func startBridge(t *testing.T, edit func(*Config)) *testBridge {
+	t.Helper()
+	config := defaultConfig()
+	config.Gateway.Token = ""
+	config.Gateway.IdentityPath = gatewaytest.IdentityFile(t)
+	config.Gateway.Reconnect.InitialDelay = 10 * time.Millisecond
+	config.Gateway.Reconnect.MaxDelay = 50 * time.Millisecond
+	config.Session.Directory = t.TempDir()
+	config.Log.Level = "debug"
+	edit(config)
+
+	b, err := NewBridge(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w := io.Pipe()
+	stdout := b.out
+	b.out = protocol.NewWriter(w, outputBuffer)
+	stdout.Close()
+
+	tb := &testBridge{Bridge: b, t: t, msgs: make(chan message, outputBuffer)}
+	go func() {
+		defer close(tb.msgs)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var msg message
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				t.Errorf("bad output %q: %v", scanner.Text(), err)
+				continue
+			}
+			tb.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		b.Close()
+		w.Close()
+		for range tb.msgs {
+		}
+	})
+	return tb
+}
+
+// request hands the bridge a request as if read from stdin.
+func (tb *testBridge) request(id int, method string, params interface{}) {
+	tb.t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	tb.handleRequest(&protocol.Request{JSONRPC: "2.0", Method: method, Params: raw, ID: &id})
+}
+
+// next returns the next message the bridge wrote.
+func (tb *testBridge) next() message {
+	tb.t.Helper()
+	select {
+	case msg, ok := <-tb.msgs:
+		if !ok {
+			tb.t.Fatal("bridge output closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		tb.t.Fatal("timed out waiting for the bridge")
+		return message{}
+	}
+}
+
+// waitFor skips messages up to the next notification of method.
+func (tb *testBridge) waitFor(method string) message {
+	tb.t.Helper()
+	for {
+		if msg := tb.next(); msg.Method == method {
+			return msg
+		}
+	}
+}
+
+// response skips messages up to the response to id.
+func (tb *testBridge) response(id int) message {
+	tb.t.Helper()
+	for {
+		if msg := tb.next(); msg.ID != nil && *msg.ID == id {
+			return msg
+		}
+	}
+}
+
+// slowScript streams a numbered reply to each message in steps small
+// enough for runs to overlap.
+func slowScript(_, message string) []gatewaytest.Step {
+	var steps []gatewaytest.Step
+	text := "re: " + message
+	steps = append(steps, gatewaytest.Step{Text: text, Delay: 5 * time.Millisecond})
+	for i := 0; i < 20; i++ {
+		text += fmt.Sprintf(" %d", i)
+		steps = append(steps, gatewaytest.Step{Text: text, Delay: 5 * time.Millisecond})
+	}
+	return append(steps, gatewaytest.Step{State: "final", Text: text})
+}
+
+// slowReply is the final text of slowScript.
+func slowReply(message string) string {
+	text := "re: " + message
+	for i := 0; i < 20; i++ {
+		text += fmt.Sprintf(" %d", i)
+	}
+	return text
+}
+
+func TestBridgeConcurrentRuns(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(slowScript)
+	b := newTestBridge(t, srv, nil)
+
+	// Odd sends run to the end, even ones are cancelled once they stream
+	const sends = 8
+	for id := 1; id <= sends; id++ {
+		b.request(id, "send", protocol.SendParams{Content: fmt.Sprintf("message %d", id)})
+	}
+
+	type run struct {
+		runID    string
+		streamed string
+		ended    string // Notification that ended the run
+		result   message
+		answered bool
+	}
+	runs := make(map[int]*run)
+	for id := 1; id <= sends; id++ {
+		runs[id] = &run{}
+	}
+	cancels := make(map[int]message) // Keyed by the send id
+	cancelled := make(map[int]bool)
+
+	for done := 0; done < sends+sends/2; {
+		msg := b.next()
+		if msg.ID != nil {
+			id := *msg.ID
+			if id > 100 {
+				cancels[id-100] = msg
+				done++
+				continue
+			}
+			r := runs[id]
+			if r == nil || r.answered {
+				t.Fatalf("unexpected response %+v", msg)
+			}
+			r.result, r.answered = msg, true
+			done++
+			continue
+		}
+
+		var p runParams
+		json.Unmarshal(msg.Params, &p)
+		r := runs[p.ID]
+		if r == nil {
+			continue
+		}
+		if r.ended != "" {
+			t.Errorf("send %d: %s after %s", p.ID, msg.Method, r.ended)
+		}
+		if r.runID == "" {
+			r.runID = p.RunID
+		} else if r.runID != p.RunID {
+			t.Errorf("send %d: events of runs %s and %s", p.ID, r.runID, p.RunID)
+		}
+		switch msg.Method {
+		case "stream":
+			r.streamed += p.Delta
+			if p.ID%2 == 0 && !cancelled[p.ID] {
+				cancelled[p.ID] = true
+				b.request(100+p.ID, "cancel", protocol.CancelParams{RunID: p.RunID})
+			}
+		case "final", "aborted", "run_error":
+			r.ended = msg.Method
+		}
+	}
+
+	for id := 1; id <= sends; id++ {
+		r := runs[id]
+		want := slowReply(fmt.Sprintf("message %d", id))
+		if id%2 == 1 {
+			if r.ended != "final" || r.streamed != want || string(r.result.Result) != `{"status":"ok"}` {
+				t.Errorf("send %d: ended %q with %q, answered %s %+v", id, r.ended, r.streamed, r.result.Result, r.result.Error)
+			}
+			continue
+		}
+
+		if r.ended != "aborted" || string(r.result.Result) != `{"status":"aborted"}` {
+			t.Errorf("send %d: ended %q, answered %s %+v", id, r.ended, r.result.Result, r.result.Error)
+		}
+		var result protocol.CancelResult
+		if err := json.Unmarshal(cancels[id].Result, &result); err != nil || result.Status != "cancelled" || result.RunID != r.runID {
+			t.Errorf("cancel of send %d: got %s %+v", id, cancels[id].Result, cancels[id].Error)
+		}
+		// Nothing is streamed after the cancel, but the client may have
+		// taken in more by then
+		if !strings.HasPrefix(result.Partial, r.streamed) || !strings.HasPrefix(want, result.Partial) {
+			t.Errorf("send %d: streamed %q, partial %q", id, r.streamed, result.Partial)
+		}
+	}
+
+	b.Close()
+	file, err := session.ParseFile(b.session.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users, replies int
+	for _, m := range file.Messages {
+		switch {
+		case m.Role == "User":
+			users++
+		case m.Role == "Assistant" && strings.HasSuffix(m.Body, " 19"):
+			replies++
+		}
+	}
+	if users != sends || replies != sends/2 {
+		t.Errorf("transcript has %d user messages and %d whole replies, want %d and %d", users, replies, sends, sends/2)
+	}
+}
+
+func TestBridgeCancelLatest(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(slowScript)
+	b := newTestBridge(t, srv, nil)
+
+	b.request(1, "send", protocol.SendParams{Content: "first"})
+	b.request(2, "send", protocol.SendParams{Content: "second"})
+	b.request(3, "cancel", nil)
+
+	resp := b.response(3)
+	var result protocol.CancelResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result.Status != "cancelled" {
+		t.Fatalf("cancel: got %s %+v", resp.Result, resp.Error)
+	}
+
+	// The second send was cancelled, the first one finishes
+	for {
+		msg := b.next()
+		var p runParams
+		json.Unmarshal(msg.Params, &p)
+		switch {
+		case msg.Method == "aborted" && p.ID != 2:
+			t.Fatalf("aborted send %d, want the latest", p.ID)
+		case msg.Method == "final" && p.ID == 1:
+			b.request(4, "cancel", nil)
+			if resp := b.response(4); resp.Error == nil || resp.Error.Code != protocol.ErrNoActiveRun {
+				t.Fatalf("cancel without runs: got %s %+v", resp.Result, resp.Error)
+			}
+			return
+		}
+	}
+}
+
+func TestBridgeCancelBeforeAck(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	release := make(chan struct{})
+	srv.Handle("chat.send", func(params json.RawMessage) (interface{}, *openclaw.FrameError) {
+		var p struct {
+			IdempotencyKey string `json:"idempotencyKey"`
+		}
+		json.Unmarshal(params, &p)
+		<-release
+		return map[string]string{"runId": p.IdempotencyKey, "status": "started"}, nil
+	})
+	b := newTestBridge(t, srv, nil)
+
+	// The gateway sits on the send; status and cancel are still answered
+	b.request(1, "send", protocol.SendParams{Content: "hi"})
+	waitFrames(t, srv, "chat.send")
+	b.request(2, "status", nil)
+	if resp := b.response(2); resp.Error != nil {
+		t.Fatalf("status: %+v", resp.Error)
+	}
+	b.request(3, "cancel", nil)
+
+	var result protocol.CancelResult
+	var aborted, answered bool
+	for !aborted || !answered || result.Status == "" {
+		msg := b.next()
+		switch {
+		case msg.Method == "aborted":
+			aborted = true
+		case msg.ID != nil && *msg.ID == 1:
+			answered = string(msg.Result) == `{"status":"aborted"}`
+		case msg.ID != nil && *msg.ID == 3:
+			if err := json.Unmarshal(msg.Result, &result); err != nil || result.Status != "cancelled" {
+				t.Fatalf("cancel: got %s %+v", msg.Result, msg.Error)
+			}
+		}
+	}
+
+	// Once the gateway takes the run it is aborted, after the send
+	close(release)
+	waitFrames(t, srv, "chat.send", "chat.abort")
+}
+
+func TestBridgeCancelBeforeSend(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	release := make(chan struct{})
+	srv.Handle("chat.send", func(json.RawMessage) (interface{}, *openclaw.FrameError) {
+		<-release
+		return nil, &openclaw.FrameError{Code: "RATE_LIMITED", Message: "slow down"}
+	})
+	b := newTestBridge(t, srv, nil)
+
+	// The second send waits for the first, and is cancelled meanwhile
+	b.request(1, "send", protocol.SendParams{Content: "one"})
+	b.request(2, "send", protocol.SendParams{Content: "two"})
+	waitFrames(t, srv, "chat.send")
+	b.request(3, "cancel", nil)
+	if resp := b.response(2); string(resp.Result) != `{"status":"aborted"}` {
+		t.Fatalf("cancelled send: got %s %+v", resp.Result, resp.Error)
+	}
+
+	close(release)
+	if resp := b.response(1); resp.Error == nil || resp.Error.Code != protocol.ErrGatewayError {
+		t.Fatalf("rejected send: got %s %+v", resp.Result, resp.Error)
+	}
+	b.request(4, "status", nil)
+	b.response(4)
+	waitFrames(t, srv, "chat.send")
+}
+
+// waitFrames waits until the chat frames srv received are the given
+// methods, in order.
+func waitFrames(t *testing.T, srv *gatewaytest.Server, methods ...string) {
+	t.Helper()
+	want := strings.Join(methods, ",")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var got []string
+		for _, frame := range srv.Frames() {
+			if strings.HasPrefix(frame.Method, "chat.") {
+				got = append(got, frame.Method)
+			}
+		}
+		if strings.Join(got, ",") == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gateway got %v, want %s", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBridgeStartsOffline(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "gateway.sock")
+	b := startBridge(t, func(config *Config) {
+		config.Gateway.URL = "ws://gateway/"
+		config.Gateway.Socket = socket
+	})
+	if err := b.Connect(); err == nil {
+		t.Fatal("connected without a gateway")
+	}
+	b.waitFor("reconnecting")
+
+	b.request(1, "send", protocol.SendParams{Content: "hello there"})
+	resp := b.response(1)
+	var result protocol.SendResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result.Status != "queued" {
+		t.Fatalf("send while offline: got %s %+v", resp.Result, resp.Error)
+	}
+
+	// Once the gateway is up the queued message goes out
+	srv, err := gatewaytest.NewUnixServer(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	b.waitFor("connected")
+	msg := b.waitFor("final")
+	var p runParams
+	json.Unmarshal(msg.Params, &p)
+	if p.ID != 1 || p.RunID != result.RunID {
+		t.Fatalf("final %s, want run %s of send 1", msg.Params, result.RunID)
+	}
+}
+
+// offlineBridge starts a bridge for a gateway on a Unix socket that isn't
+// up yet; see startGateway.
+func offlineBridge(t *testing.T, dir string) (*testBridge, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "gateway.sock")
+	b := startBridge(t, func(config *Config) {
+		config.Gateway.URL = "ws://gateway/"
+		config.Gateway.Socket = socket
+		if dir != "" {
+			config.Session.Directory = dir
+		}
+	})
+	b.Connect()
+	b.waitFor("reconnecting")
+	return b, socket
+}
+
+// startGateway starts the gateway an offline bridge waits for.
+func startGateway(t *testing.T, socket string) *gatewaytest.Server {
+	t.Helper()
+	srv, err := gatewaytest.NewUnixServer(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// sentMessages returns the messages of the chat.send frames srv received.
+func sentMessages(srv *gatewaytest.Server) []string {
+	var sent []string
+	for _, frame := range srv.Frames() {
+		if frame.Method != "chat.send" {
+			continue
+		}
+		var p struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(frame.Params, &p)
+		sent = append(sent, p.Message)
+	}
+	return sent
+}
+
+// queueSend sends content while the bridge is offline and returns its
+// runId.
+func (tb *testBridge) queueSend(id int, content string) string {
+	tb.t.Helper()
+	tb.request(id, "send", protocol.SendParams{Content: content})
+	resp := tb.response(id)
+	var result protocol.SendResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result.Status != "queued" {
+		tb.t.Fatalf("send %q while offline: got %s %+v", content, resp.Result, resp.Error)
+	}
+	return result.RunID
+}
+
+// waitFinals reads up to the final notifications of n runs and returns
+// their runIds in the order they ended.
+func (tb *testBridge) waitFinals(n int) []string {
+	tb.t.Helper()
+	var runIDs []string
+	for len(runIDs) < n {
+		msg := tb.waitFor("final")
+		var p runParams
+		json.Unmarshal(msg.Params, &p)
+		runIDs = append(runIDs, p.RunID)
+	}
+	return runIDs
+}
+
+func TestBridgeQueue(t *testing.T) {
+	b, socket := offlineBridge(t, "")
+
+	var runIDs []string
+	for id, content := range []string{"one", "two", "three", "four"} {
+		runIDs = append(runIDs, b.queueSend(id+1, content))
+	}
+
+	b.request(5, "queue_list", nil)
+	var list protocol.QueueListResult
+	if resp := b.response(5); json.Unmarshal(resp.Result, &list) != nil || len(list.Entries) != 4 {
+		t.Fatalf("queue_list: got %s %+v", resp.Result, resp.Error)
+	}
+	for i, e := range list.Entries {
+		if e.RunID != runIDs[i] || e.SessionKey != openclaw.DefaultSessionKey || e.QueuedAt == 0 {
+			t.Errorf("entry %d: got %+v, want run %s", i, e, runIDs[i])
+		}
+	}
+
+	b.request(6, "queue_drop", protocol.QueueDropParams{RunID: runIDs[1]})
+	if resp := b.response(6); string(resp.Result) != `{"dropped":1}` {
+		t.Fatalf("queue_drop: got %s %+v", resp.Result, resp.Error)
+	}
+	b.request(7, "queue_drop", protocol.QueueDropParams{RunID: runIDs[1]})
+	if resp := b.response(7); resp.Error == nil || resp.Error.Code != protocol.ErrInvalidParams {
+		t.Fatalf("queue_drop of a dropped message: got %s %+v", resp.Result, resp.Error)
+	}
+
+	// The rest goes out in order once the gateway is up
+	srv := startGateway(t, socket)
+	b.waitFor("connected")
+	if got := b.waitFinals(3); strings.Join(got, ",") != strings.Join([]string{runIDs[0], runIDs[2], runIDs[3]}, ",") {
+		t.Errorf("finals of %v, want %v without %s", got, runIDs, runIDs[1])
+	}
+	if got := sentMessages(srv); strings.Join(got, ",") != "one,three,four" {
+		t.Errorf("gateway got %q", got)
+	}
+
+	// A run may end before the gateway acknowledges its send, which is
+	// when the message leaves the outbox
+	for id := 8; ; id++ {
+		b.request(id, "queue_list", nil)
+		resp := b.response(id)
+		if string(resp.Result) == `{"entries":[]}` {
+			break
+		}
+		if id == 100 {
+			t.Fatalf("queue_list after the flush: got %s %+v", resp.Result, resp.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBridgeQueueDropAll(t *testing.T) {
+	b, _ := offlineBridge(t, "")
+	b.queueSend(1, "one")
+	b.queueSend(2, "two")
+
+	b.request(3, "queue_drop", nil)
+	if resp := b.response(3); string(resp.Result) != `{"dropped":2}` {
+		t.Fatalf("queue_drop: got %s %+v", resp.Result, resp.Error)
+	}
+	b.request(4, "status", nil)
+	var status protocol.StatusResult
+	if resp := b.response(4); json.Unmarshal(resp.Result, &status) != nil || status.Queued != 0 {
+		t.Fatalf("status: got %s %+v", resp.Result, resp.Error)
+	}
+}
+
+func TestBridgeOutboxReload(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := offlineBridge(t, dir)
+	first := b.queueSend(1, "one")
+	second := b.queueSend(2, "two")
+	b.Close()
+
+	// A new bridge on the same session sends what the old one queued
+	b, socket := offlineBridge(t, dir)
+	b.request(1, "queue_list", nil)
+	var list protocol.QueueListResult
+	if resp := b.response(1); json.Unmarshal(resp.Result, &list) != nil || len(list.Entries) != 2 {
+		t.Fatalf("queue_list after restart: got %s %+v", resp.Result, resp.Error)
+	}
+
+	srv := startGateway(t, socket)
+	b.waitFor("connected")
+	if got := b.waitFinals(2); strings.Join(got, ",") != first+","+second {
+		t.Errorf("finals of %v, want %s and %s", got, first, second)
+	}
+	if got := sentMessages(srv); strings.Join(got, ",") != "one,two" {
+		t.Errorf("gateway got %q", got)
+	}
+}
+
+func TestBridgeActivity(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	call := openclaw.ContentPart{Type: "toolCall", ID: "call-1", Name: "exec", Arguments: json.RawMessage(`{"cmd":"ls"}`)}
+	thought := openclaw.ContentPart{Type: "thinking", Thinking: "Let me look"}
+	srv.SetScript(func(_, message string) []gatewaytest.Step {
+		return []gatewaytest.Step{
+			{Stream: "thinking", Data: map[string]string{"delta": "Let me"}},
+			{Stream: "thinking", Data: map[string]string{"delta": " look"}},
+			// The snapshot repeats the reasoning and announces the call,
+			// which the tool stream reports too
+			{Parts: []openclaw.ContentPart{thought, call}},
+			{Stream: "tool", Data: map[string]interface{}{"phase": "start", "name": "exec", "toolCallId": "call-1", "args": call.Arguments}},
+			{Stream: "tool", Data: map[string]interface{}{"phase": "result", "name": "exec", "toolCallId": "call-1", "result": "a.go"}},
+			{Parts: []openclaw.ContentPart{thought, call, {Type: "toolResult", ToolCallID: "call-1", Content: json.RawMessage(`"a.go"`)}}, Text: "Found a.go"},
+			{State: "final", Text: "Found a.go"},
+		}
+	})
+	b := newTestBridge(t, srv, nil)
+
+	b.request(1, "send", protocol.SendParams{Content: "ls"})
+	var got []string
+	for {
+		msg := b.next()
+		switch msg.Method {
+		case "thinking":
+			var p protocol.ThinkingParams
+			json.Unmarshal(msg.Params, &p)
+			got = append(got, "thinking "+p.Delta)
+		case "tool":
+			var p protocol.ToolParams
+			json.Unmarshal(msg.Params, &p)
+			if p.ID != 1 || p.CallID != "call-1" || p.Name != "exec" {
+				t.Errorf("tool %s", msg.Params)
+			}
+			got = append(got, fmt.Sprintf("tool %s %s %s", p.Phase, p.Status, string(p.Args)+string(p.Result)))
+		case "stream":
+			got = append(got, "stream")
+		case "final":
+			want := []string{
+				"thinking Let me",
+				"thinking  look",
+				`tool start running {"cmd":"ls"}`,
+				`tool result ok "a.go"`,
+				"stream",
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("got notifications\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+			return
+		}
+	}
+}
+
+// frameParams returns the params of the frames of method srv received.
+func frameParams(srv *gatewaytest.Server, method string) []string {
+	var params []string
+	for _, frame := range srv.Frames() {
+		if frame.Method == method {
+			params = append(params, string(frame.Params))
+		}
+	}
+	return params
+}
+
+// currentKey returns the session key the bridge reports in status.
+func (tb *testBridge) currentKey(id int) string {
+	tb.t.Helper()
+	tb.request(id, "status", nil)
+	var status protocol.StatusResult
+	if resp := tb.response(id); json.Unmarshal(resp.Result, &status) != nil {
+		tb.t.Fatalf("status: got %s %+v", resp.Result, resp.Error)
+	}
+	return status.SessionID
+}
+
+func TestBridgeSessions(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	ok := func(json.RawMessage) (interface{}, *openclaw.FrameError) { return map[string]bool{"ok": true}, nil }
+	srv.Handle("sessions.list", func(json.RawMessage) (interface{}, *openclaw.FrameError) {
+		return map[string]interface{}{"sessions": []map[string]interface{}{
+			{"key": "main", "updatedAt": 1700000000000},
+			{"key": "proj", "label": "Project"},
+		}}, nil
+	})
+	srv.Handle("sessions.patch", ok)
+	srv.Handle("sessions.reset", ok)
+	b := newTestBridge(t, srv, nil)
+
+	b.request(1, "sessions.list", nil)
+	want := `{"current":"main","sessions":[{"key":"main","updated_at":1700000000000,"current":true},{"key":"proj","label":"Project","current":false}]}`
+	if resp := b.response(1); string(resp.Result) != want {
+		t.Fatalf("sessions.list: got %s %+v", resp.Result, resp.Error)
+	}
+
+	// Creating a session switches to it
+	b.request(2, "sessions.create", protocol.SessionParams{Key: "notes", Label: "Notes"})
+	if msg := b.waitFor("session_changed"); string(msg.Params) != `{"key":"notes"}` {
+		t.Fatalf("session_changed %s", msg.Params)
+	}
+	if resp := b.response(2); string(resp.Result) != `{"key":"notes","status":"created"}` {
+		t.Fatalf("sessions.create: got %s %+v", resp.Result, resp.Error)
+	}
+	if got := frameParams(srv, "sessions.patch"); len(got) != 1 || got[0] != `{"key":"notes","label":"Notes"}` {
+		t.Errorf("gateway got sessions.patch %v", got)
+	}
+	if key := b.currentKey(3); key != "notes" {
+		t.Errorf("current session %q after create", key)
+	}
+
+	b.request(4, "sessions.switch", protocol.SessionParams{Key: "proj"})
+	if resp := b.response(4); string(resp.Result) != `{"key":"proj","status":"switched"}` {
+		t.Fatalf("sessions.switch: got %s %+v", resp.Result, resp.Error)
+	}
+	if key := b.currentKey(5); key != "proj" {
+		t.Errorf("current session %q after switch", key)
+	}
+
+	// Reset and send default to the current session
+	b.request(6, "sessions.reset", nil)
+	if resp := b.response(6); string(resp.Result) != `{"key":"proj","status":"reset"}` {
+		t.Fatalf("sessions.reset: got %s %+v", resp.Result, resp.Error)
+	}
+	b.request(7, "sessions.reset", protocol.SessionParams{Key: "main"})
+	b.response(7)
+	if got := frameParams(srv, "sessions.reset"); strings.Join(got, ",") != `{"key":"proj"},{"key":"main"}` {
+		t.Errorf("gateway got sessions.reset %v", got)
+	}
+	b.request(8, "send", protocol.SendParams{Content: "hi"})
+	b.waitFor("final")
+	if got := frameParams(srv, "chat.send"); len(got) != 1 || !strings.Contains(got[0], `"sessionKey":"proj"`) {
+		t.Errorf("gateway got chat.send %v", got)
+	}
+}
+
+func TestBridgeSessionsErrors(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	for _, method := range []string{"sessions.list", "sessions.patch", "sessions.reset"} {
+		srv.Handle(method, func(json.RawMessage) (interface{}, *openclaw.FrameError) {
+			return nil, &openclaw.FrameError{Code: "FORBIDDEN", Message: "not allowed"}
+		})
+	}
+	b := newTestBridge(t, srv, nil)
+
+	tests := []struct {
+		method string
+		params interface{}
+		code   int
+		data   string // Gateway method in the error data, if any
+	}{
+		{"sessions.list", nil, protocol.ErrGatewayError, "sessions.list"},
+		{"sessions.create", protocol.SessionParams{Key: "notes"}, protocol.ErrGatewayError, "sessions.patch"},
+		{"sessions.create", protocol.SessionParams{}, protocol.ErrInvalidParams, ""},
+		{"sessions.switch", protocol.SessionParams{}, protocol.ErrInvalidParams, ""},
+		{"sessions.reset", nil, protocol.ErrGatewayError, "sessions.reset"},
+	}
+	for i, tt := range tests {
+		b.request(i+1, tt.method, tt.params)
+		resp := b.response(i + 1)
+		if resp.Error == nil || resp.Error.Code != tt.code {
+			t.Errorf("%s %+v: got %s %+v, want error %d", tt.method, tt.params, resp.Result, resp.Error, tt.code)
+			continue
+		}
+		if tt.data == "" {
+			continue
+		}
+		raw, _ := json.Marshal(resp.Error.Data)
+		var data protocol.GatewayErrorData
+		if json.Unmarshal(raw, &data) != nil || data.Method != tt.data || data.Code != "FORBIDDEN" || data.Message != "not allowed" {
+			t.Errorf("%s: error data %s", tt.method, raw)
+		}
+	}
+
+	// A failed create doesn't switch
+	if key := b.currentKey(10); key != openclaw.DefaultSessionKey {
+		t.Errorf("current session %q after failed create", key)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/albxllm/moltstream/internal/session"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+const usage = `usage: moltstream [command]
+
+Without a command, runs the bridge on stdin/stdout.
+
+Commands:
+  identity init [-path file] [-force]   Generate a device identity
+  identity show [-path file]            Print the device ID
+`
+
+// runCommand handles the command-line subcommands.
+func runCommand(config *Config, args []string) error {
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+
+	if len(args) < 2 || args[0] != "identity" {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	switch args[1] {
+	case "init":
+		return identityInit(config, args[2:])
+	case "show":
+		return identityShow(config, args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown identity command %q", args[1])
+	}
+}
+
+func identityPathFlag(fs *flag.FlagSet, config *Config) *string {
+	return fs.String("path", config.Gateway.IdentityPath, "identity file (default ~/.openclaw/identity/device.json)")
+}
+
+func resolveIdentityPath(path string) (string, error) {
+	if path == "" {
+		return openclaw.DefaultIdentityPath()
+	}
+	return session.ExpandPath(path)
+}
+
+func identityInit(config *Config, args []string) error {
+	fs := flag.NewFlagSet("identity init", flag.ContinueOnError)
+	path := identityPathFlag(fs, config)
+	force := fs.Bool("force", false, "replace an existing identity")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	target, err := resolveIdentityPath(*path)
+	if err != nil {
+		return err
+	}
+
+	identity, err := openclaw.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+	err = openclaw.SaveIdentity(target, identity, *force)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w (use -force to replace it)", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s\nDevice ID: %s\n", target, identity.DeviceID)
+	return nil
+}
+
+func identityShow(config *Config, args []string) error {
+	fs := flag.NewFlagSet("identity show", flag.ContinueOnError)
+	path := identityPathFlag(fs, config)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	target, err := resolveIdentityPath(*path)
+	if err != nil {
+		return err
+	}
+
+	identity, _, err := openclaw.LoadIdentity(target)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\nDevice ID: %s\n", target, identity.DeviceID)
+	return nil
+}
@@ -2,39 +2,135 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/albxllm/moltstream/internal/gateway"
+	"github.com/albxllm/moltstream/internal/logging"
 	"github.com/albxllm/moltstream/internal/protocol"
 	"github.com/albxllm/moltstream/internal/session"
+	"github.com/albxllm/moltstream/pkg/openclaw"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	Gateway struct {
-		URL   string `yaml:"url"`
-		Token string `yaml:"token"`
+		URL          string `yaml:"url"`
+		Token        string `yaml:"token"`
+		SessionKey   string `yaml:"session_key"`
+		IdentityPath string `yaml:"identity_path"` // Default: ~/.openclaw/identity/device.json
+		Proxy        string `yaml:"proxy"`         // Default: HTTPS_PROXY/ALL_PROXY; "none" to disable
+		Socket       string `yaml:"socket"`        // Unix socket path, overrides host and proxy
+		Reconnect    struct {
+			InitialDelay time.Duration `yaml:"initial_delay"`
+			MaxDelay     time.Duration `yaml:"max_delay"`
+		} `yaml:"reconnect"`
+		TLS struct {
+			CAFile     string   `yaml:"ca_file"`
+			CertFile   string   `yaml:"cert_file"`
+			KeyFile    string   `yaml:"key_file"`
+			ServerName string   `yaml:"server_name"`
+			PinnedSPKI []string `yaml:"pinned_spki"`
+		} `yaml:"tls"`
+		Heartbeat struct {
+			Interval time.Duration `yaml:"interval"` // 0 disables pings
+			Timeout  time.Duration `yaml:"timeout"`
+		} `yaml:"heartbeat"`
 	} `yaml:"gateway"`
+	Run struct {
+		FirstTokenTimeout time.Duration `yaml:"first_token_timeout"` // 0 disables
+		IdleTimeout       time.Duration `yaml:"idle_timeout"`        // 0 disables
+		ToolTimeout       time.Duration `yaml:"tool_timeout"`        // 0 disables
+		AbortOnStall      bool          `yaml:"abort_on_stall"`
+	} `yaml:"run"`
 	Session struct {
 		Directory    string `yaml:"directory"`
 		MaxSizeBytes int64  `yaml:"max_size_bytes"`
 		AutoArchive  bool   `yaml:"auto_archive"`
 	} `yaml:"session"`
+	Log struct {
+		Level        string `yaml:"level"`
+		File         string `yaml:"file"` // Default: moltstream.log in the session directory
+		MaxSizeBytes int64  `yaml:"max_size_bytes"`
+		MaxBackups   int    `yaml:"max_backups"`
+	} `yaml:"log"`
+}
+
+// Version is set at build time (see Makefile) and reported to the gateway.
+var Version = "dev"
+
+// gatewayMethods maps bridge methods to the gateway method they depend on.
+var gatewayMethods = map[string]string{
+	"send":            "chat.send",
+	"cancel":          "chat.abort",
+	"history":         "chat.history",
+	"sessions.list":   "sessions.list",
+	"sessions.create": "sessions.patch",
+	"sessions.reset":  "sessions.reset",
 }
 
+// defaultHistoryLimit is used when a history request doesn't set a limit.
+const defaultHistoryLimit = 200
+
+// outputBuffer is how many messages may queue for stdout before senders block.
+const outputBuffer = 256
+
 type Bridge struct {
-	config  *Config
-	client  *gateway.Client
-	session *session.Manager
-	encoder *json.Encoder
-	decoder *json.Decoder
-	reqID   int
+	config     *Config
+	client     *gateway.Client
+	session    *session.Manager
+	transcript *session.Transcript
+	outbox     *session.Outbox
+	out        *protocol.Writer // All stdout goes through here
+	log        *slog.Logger
+	logLevel   *slog.LevelVar
+	logFile    io.Closer
+
+	// ctx bounds gateway calls; Close cancels it and waits for wg, which
+	// tracks the goroutines serving slow requests
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	mu         sync.Mutex
+	runs       map[string]*pendingRun // Keyed by gateway runId
+	queued     map[string]int         // Send request ids of outbox entries, keyed by runId
+	flushing   bool                   // flushOutbox is running
+	sessionKey string                 // Current gateway session
+	lastSend   chan struct{}          // Closed once the latest send is through, see handleSend
+}
+
+// pendingRun links a gateway run to the send request awaiting its result.
+type pendingRun struct {
+	reqID    int
+	started  time.Time
+	answered bool // The send was already answered: it was queued, or stalled
+
+	// sending is set until the gateway answers the chat.send. A run
+	// cancelled meanwhile is only marked, and aborted once it is accepted,
+	// so the abort can't overtake the send.
+	sending   bool
+	cancelled bool
+
+	// Stall detection, see watchLocked
+	timer    *time.Timer
+	lastSeen time.Time // Last event of the run; zero before the first
+	tools    int       // Tool calls running, during which the run may be quiet
+	stalled  bool
 }
 
 func main() {
@@ -46,13 +142,20 @@ func main() {
 		log.Fatalf("load config: %v", err)
 	}
 
+	if len(os.Args) > 1 {
+		if err := runCommand(config, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Token from env (new name takes priority)
 	if envToken := os.Getenv("MOLTSTREAM_OPENCLAW_GATEWAY_TOKEN"); envToken != "" {
 		config.Gateway.Token = envToken
 	} else if config.Gateway.Token == "${OPENCLAW_TOKEN}" {
 		config.Gateway.Token = os.Getenv("OPENCLAW_TOKEN")
 	}
-	
+
 	// Tailscale IP override
 	if tsIP := os.Getenv("MOLTSTREAM_TAILSCALE_GATEWAY_IP"); tsIP != "" {
 		config.Gateway.URL = "ws://" + tsIP + ":18789"
@@ -60,6 +163,7 @@ func main() {
 
 	bridge, err := NewBridge(config)
 	if err != nil {
+		log.SetOutput(os.Stderr)
 		log.Fatalf("create bridge: %v", err)
 	}
 
@@ -72,13 +176,36 @@ func main() {
 		os.Exit(0)
 	}()
 
-	// Connect to gateway
+	// Connect to gateway. If it is unreachable the client keeps redialing
+	// and sends are queued until it is back.
 	if err := bridge.Connect(); err != nil {
-		log.Fatalf("connect: %v", err)
+		slog.Warn("gateway unreachable", "component", "bridge", "err", err)
 	}
 
 	// Process stdin
 	bridge.Run()
+	bridge.Close()
+}
+
+func defaultConfig() *Config {
+	var config Config
+	config.Gateway.URL = "ws://127.0.0.1:18789"
+	config.Gateway.Token = "${OPENCLAW_TOKEN}"
+	config.Gateway.SessionKey = openclaw.DefaultSessionKey
+	config.Gateway.Reconnect.InitialDelay = openclaw.DefaultInitialDelay
+	config.Gateway.Reconnect.MaxDelay = openclaw.DefaultMaxDelay
+	config.Gateway.Heartbeat.Interval = openclaw.DefaultPingInterval
+	config.Gateway.Heartbeat.Timeout = openclaw.DefaultPongTimeout
+	config.Run.FirstTokenTimeout = defaultFirstTokenTimeout
+	config.Run.IdleTimeout = defaultIdleTimeout
+	config.Run.ToolTimeout = defaultToolTimeout
+	config.Session.Directory = "~/.local/share/moltstream"
+	config.Session.MaxSizeBytes = 1073741824 // 1GB
+	config.Session.AutoArchive = true
+	config.Log.Level = "info"
+	config.Log.MaxSizeBytes = 10 * 1024 * 1024 // 10MB
+	config.Log.MaxBackups = 3
+	return &config
 }
 
 func loadConfig() (*Config, error) {
@@ -89,72 +216,149 @@ func loadConfig() (*Config, error) {
 
 	configPath := filepath.Join(home, ".config", "moltstream", "config.yaml")
 
+	config := defaultConfig()
+
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		// Return defaults if no config
-		return &Config{
-			Gateway: struct {
-				URL   string `yaml:"url"`
-				Token string `yaml:"token"`
-			}{
-				URL:   "ws://127.0.0.1:18789",
-				Token: "${OPENCLAW_TOKEN}",
-			},
-			Session: struct {
-				Directory    string `yaml:"directory"`
-				MaxSizeBytes int64  `yaml:"max_size_bytes"`
-				AutoArchive  bool   `yaml:"auto_archive"`
-			}{
-				Directory:    "~/.local/share/moltstream",
-				MaxSizeBytes: 1073741824, // 1GB
-				AutoArchive:  true,
-			},
-		}, nil
+		return config, nil
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	// Unmarshal over the defaults so omitted keys keep their default values
+	if err := yaml.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
 
-	return &config, nil
+	return config, nil
 }
 
 func NewBridge(config *Config) (*Bridge, error) {
+	// Logging comes first so every component picks up the file handler
+	logLevel, logFile, err := setupLogging(config)
+	if err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+
 	sess, err := session.NewManager(
 		config.Session.Directory,
 		config.Session.MaxSizeBytes,
 		config.Session.AutoArchive,
 	)
 	if err != nil {
+		logFile.Close()
 		return nil, fmt.Errorf("session manager: %w", err)
 	}
 
-	client := gateway.NewClient(config.Gateway.URL, config.Gateway.Token)
+	outbox, err := session.OpenOutbox(sess.OutboxPath())
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+
+	identityPath, err := session.ExpandPath(config.Gateway.IdentityPath)
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+	socket, err := session.ExpandPath(config.Gateway.Socket)
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+	opts := []openclaw.Option{
+		openclaw.WithToken(config.Gateway.Token),
+		openclaw.WithIdentityFile(identityPath),
+		openclaw.WithBackoff(config.Gateway.Reconnect.InitialDelay, config.Gateway.Reconnect.MaxDelay),
+		openclaw.WithHeartbeat(config.Gateway.Heartbeat.Interval, config.Gateway.Heartbeat.Timeout),
+		openclaw.WithClientVersion(Version),
+		openclaw.WithConnectRetry(),
+		openclaw.WithTransport(openclaw.Transport{Proxy: config.Gateway.Proxy, Socket: socket}),
+	}
+	if tlsOpts := tlsOptions(config); !tlsOpts.IsZero() {
+		tlsConfig, err := tlsOpts.Config()
+		if err != nil {
+			logFile.Close()
+			return nil, fmt.Errorf("gateway tls: %w", err)
+		}
+		opts = append(opts, openclaw.WithTLS(tlsConfig))
+	}
+
+	client, err := gateway.NewClient(config.Gateway.URL, opts...)
+	if errors.Is(err, openclaw.ErrNoIdentity) {
+		logFile.Close()
+		return nil, fmt.Errorf("%w (run `moltstream identity init` to create one)", err)
+	}
+	if err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("gateway client: %w", err)
+	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Bridge{
-		config:  config,
-		client:  client,
-		session: sess,
-		encoder: json.NewEncoder(os.Stdout),
-		decoder: json.NewDecoder(os.Stdin),
+		ctx:        ctx,
+		cancel:     cancel,
+		config:     config,
+		client:     client,
+		session:    sess,
+		transcript: session.NewTranscript(sess),
+		outbox:     outbox,
+		out:        protocol.NewWriter(os.Stdout, outputBuffer),
+		log:        logging.Component("bridge"),
+		logLevel:   logLevel,
+		logFile:    logFile,
+		runs:       make(map[string]*pendingRun),
+		queued:     make(map[string]int),
+		sessionKey: config.Gateway.SessionKey,
 	}, nil
 }
 
-func (b *Bridge) Connect() error {
-	b.client.OnMessage(b.handleGatewayMessage)
-	b.client.OnError(b.handleGatewayError)
+func tlsOptions(config *Config) openclaw.TLSOptions {
+	t := config.Gateway.TLS
+	expand := func(path string) string {
+		if expanded, err := session.ExpandPath(path); err == nil {
+			return expanded
+		}
+		return path
+	}
+	return openclaw.TLSOptions{
+		CAFile:     expand(t.CAFile),
+		CertFile:   expand(t.CertFile),
+		KeyFile:    expand(t.KeyFile),
+		ServerName: t.ServerName,
+		PinnedSPKI: t.PinnedSPKI,
+	}
+}
 
-	if err := b.client.Connect(); err != nil {
-		return err
+func setupLogging(config *Config) (*slog.LevelVar, io.Closer, error) {
+	path := config.Log.File
+	if path == "" {
+		dir, err := session.ExpandPath(config.Session.Directory)
+		if err != nil {
+			return nil, nil, err
+		}
+		path = filepath.Join(dir, "moltstream.log")
+	}
+	path, err := session.ExpandPath(path)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Notify nvim of connection
-	b.sendNotification("connected", map[string]interface{}{
-		"gateway": b.config.Gateway.URL,
+	return logging.Setup(logging.Options{
+		Level:        config.Log.Level,
+		Path:         path,
+		MaxSizeBytes: config.Log.MaxSizeBytes,
+		MaxBackups:   config.Log.MaxBackups,
 	})
+}
 
-	return nil
+func (b *Bridge) Connect() error {
+	b.client.OnRunEvent(b.handleRunEvent)
+	b.client.OnError(b.handleGatewayError)
+	b.client.OnConnected(b.handleGatewayConnected)
+	b.client.OnReconnecting(b.handleGatewayReconnecting)
+	b.client.OnPairingRequired(b.handlePairingRequired)
+
+	return b.client.Connect(b.ctx)
 }
 
 func (b *Bridge) Run() {
@@ -177,7 +381,7 @@ func (b *Bridge) Run() {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("stdin error: %v", err)
+		b.log.Error("stdin", "err", err)
 	}
 }
 
@@ -187,6 +391,12 @@ func (b *Bridge) handleRequest(req *protocol.Request) {
 		id = *req.ID
 	}
 
+	if method, ok := gatewayMethods[req.Method]; ok && !b.client.Supports(method) {
+		b.sendError(id, protocol.ErrUnsupported,
+			fmt.Sprintf("gateway does not support %s", method))
+		return
+	}
+
 	switch req.Method {
 	case "send":
 		var params protocol.SendParams
@@ -194,55 +404,356 @@ func (b *Bridge) handleRequest(req *protocol.Request) {
 			b.sendError(id, protocol.ErrInvalidParams, "invalid params")
 			return
 		}
-		b.handleSend(id, params.Content)
+		b.handleSend(id, params)
+
+	case "cancel":
+		var params protocol.CancelParams
+		if !b.decodeParams(id, req, &params) {
+			return
+		}
+		b.handleCancel(id, params.RunID)
 
 	case "status":
 		b.handleStatus(id)
 
+	case "queue_list":
+		b.handleQueueList(id)
+
+	case "queue_drop":
+		var params protocol.QueueDropParams
+		if !b.decodeParams(id, req, &params) {
+			return
+		}
+		b.handleQueueDrop(id, params.RunID)
+
+	case "capabilities":
+		b.sendResult(id, b.capabilities())
+
 	case "reconnect":
 		b.handleReconnect(id)
 
 	case "archive":
 		b.handleArchive(id)
 
+	case "log_level":
+		var params protocol.LogLevelParams
+		if !b.decodeParams(id, req, &params) {
+			return
+		}
+		b.handleLogLevel(id, params.Level)
+
 	case "session_path":
 		b.handleSessionPath(id)
 
 	case "history":
-		b.handleHistory(id)
+		var params protocol.HistoryParams
+		if !b.decodeParams(id, req, &params) {
+			return
+		}
+		// Don't block stdin on the gateway round trip
+		b.spawn(func() { b.handleHistory(id, params) })
+
+	case "sessions.list":
+		b.spawn(func() { b.handleSessionsList(id) })
+
+	case "sessions.create":
+		var params protocol.SessionParams
+		if !b.decodeParams(id, req, &params) {
+			return
+		}
+		b.spawn(func() { b.handleSessionsCreate(id, params) })
+
+	case "sessions.switch":
+		var params protocol.SessionParams
+		if !b.decodeParams(id, req, &params) {
+			return
+		}
+		b.handleSessionsSwitch(id, params.Key)
+
+	case "sessions.reset":
+		var params protocol.SessionParams
+		if !b.decodeParams(id, req, &params) {
+			return
+		}
+		b.spawn(func() { b.handleSessionsReset(id, params.Key) })
 
 	default:
 		b.sendError(id, protocol.ErrMethodNotFound, "method not found")
 	}
 }
 
-func (b *Bridge) handleSend(id int, content string) {
-	if !b.client.IsConnected() {
-		b.sendError(id, protocol.ErrNotConnected, "not connected to gateway")
+// decodeParams unmarshals optional request params into v, answering the
+// request with ErrInvalidParams and returning false if they don't parse.
+// Missing, null and empty array params (an empty Lua table encodes as
+// []) leave v as it is.
+func (b *Bridge) decodeParams(id int, req *protocol.Request, v interface{}) bool {
+	switch string(bytes.TrimSpace(req.Params)) {
+	case "", "null", "[]":
+		return true
+	}
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		b.sendError(id, protocol.ErrInvalidParams, "invalid params")
+		return false
+	}
+	return true
+}
+
+// currentSession returns key, or the current session key if key is empty.
+func (b *Bridge) currentSession(key string) string {
+	if key != "" {
+		return key
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sessionKey
+}
+
+func (b *Bridge) handleSend(id int, params protocol.SendParams) {
+	runID := openclaw.NewRunID()
+	b.mu.Lock()
+	sessionKey := params.SessionKey
+	if sessionKey == "" {
+		sessionKey = b.sessionKey
+	}
+	if err := b.transcript.User(params.Content, time.Now()); err != nil {
+		b.log.Error("transcript", "err", err)
+	}
+	entry := session.OutboxEntry{
+		RunID:      runID,
+		SessionKey: sessionKey,
+		Content:    params.Content,
+		QueuedAt:   time.Now(),
+	}
+
+	// While anything is queued, later sends queue behind it to keep order
+	if !b.client.IsConnected() || b.flushing || b.outbox.Len() > 0 {
+		b.queueLocked(id, entry)
+		b.mu.Unlock()
+		b.startFlush()
 		return
 	}
 
-	if err := b.client.Send(content); err != nil {
-		b.sendError(id, protocol.ErrGatewayError, err.Error())
+	// Register the run before sending so a cancel read after this send,
+	// and its events, which may arrive before the gateway acknowledges the
+	// send, find it
+	run := &pendingRun{reqID: id, started: time.Now(), sending: true}
+	b.runs[runID] = run
+	b.watchLocked(runID, run)
+	prev, done := b.lastSend, make(chan struct{})
+	b.lastSend = done
+	b.mu.Unlock()
+
+	// Don't block stdin on the gateway round trip; sends still go out one
+	// after another, in the order they were read
+	b.spawn(func() {
+		defer close(done)
+		if prev != nil {
+			select {
+			case <-prev:
+			case <-b.ctx.Done():
+				return
+			}
+		}
+		b.startRun(entry, run)
+	})
+}
+
+// startRun sends the chat.send of a run registered by handleSend. The
+// response comes async via handleRunEvent; only a rejected send is
+// answered here.
+func (b *Bridge) startRun(entry session.OutboxEntry, run *pendingRun) {
+	runID := entry.RunID
+	b.mu.Lock()
+	if run.cancelled {
+		b.mu.Unlock()
+		return
+	}
+	if b.flushing || b.outbox.Len() > 0 {
+		// An earlier send was queued meanwhile; queue behind it
+		run.unwatch()
+		delete(b.runs, runID)
+		b.queueLocked(run.reqID, entry)
+		b.mu.Unlock()
+		b.startFlush()
+		return
+	}
+	b.mu.Unlock()
+
+	err := b.client.Send(b.ctx, runID, entry.SessionKey, entry.Content)
+
+	b.mu.Lock()
+	if b.sentLocked(runID, run, err) || err == nil {
+		b.mu.Unlock()
+		return
+	}
+	run.unwatch()
+	delete(b.runs, runID)
+	if err := b.transcript.Finish(runID); err != nil {
+		b.log.Error("transcript", "err", err)
+	}
+	if !retryable(err) {
+		b.sendGatewayError(run.reqID, err)
+		b.mu.Unlock()
 		return
 	}
+	// The gateway may or may not have the message; resending it later
+	// with the same runId is safe either way
+	b.log.Info("queueing send", "run", runID, "err", err)
+	b.queueLocked(run.reqID, entry)
+	b.mu.Unlock()
+	b.startFlush()
+}
 
-	// Response will come async via handleGatewayMessage
-	b.reqID = id
+// sentLocked records that the chat.send of a run was answered, with err
+// if it failed, and reports whether the run was cancelled meanwhile. Such
+// a run is aborted now if the gateway accepted it. b.mu must be held.
+func (b *Bridge) sentLocked(runID string, run *pendingRun, err error) bool {
+	run.sending = false
+	if !run.cancelled {
+		return false
+	}
+	if err == nil {
+		run.cancelled = false
+		b.abortLocked(runID, run)
+	}
+	return true
+}
+
+// abortLocked asks the gateway to stop a run the bridge dropped and
+// returns the text received so far. A run whose chat.send is still out is
+// aborted by sentLocked once it is answered. b.mu must be held.
+func (b *Bridge) abortLocked(runID string, run *pendingRun) string {
+	if run.sending {
+		run.cancelled = true
+		return ""
+	}
+	partial, err := b.client.Abort(runID)
+	if err != nil && !errors.Is(err, openclaw.ErrNoActiveRun) {
+		// The run is already dropped locally; the caller still ends it
+		b.log.Warn("abort run", "run", runID, "err", err)
+	}
+	return partial
+}
+
+func (b *Bridge) handleCancel(id int, runID string) {
+	// Held until the aborted notification is queued, see handleRunEvent
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if runID == "" {
+		runID = b.latestRunLocked()
+	}
+	run, ok := b.runs[runID]
+	delete(b.runs, runID)
+
+	if !ok {
+		b.sendError(id, protocol.ErrNoActiveRun, "no response in progress")
+		return
+	}
+	run.unwatch()
+
+	partial := b.abortLocked(runID, run)
+	if err := b.transcript.Finish(runID); err != nil {
+		b.log.Error("transcript", "err", err)
+	}
+
+	b.sendNotification("aborted", protocol.AbortedParams{
+		RunID: runID,
+		ID:    run.reqID,
+	})
+	if !run.answered {
+		b.sendResult(run.reqID, map[string]string{"status": "aborted"})
+	}
+
+	b.sendResult(id, protocol.CancelResult{
+		Status:  "cancelled",
+		RunID:   runID,
+		Partial: partial,
+	})
+}
+
+// latestRunLocked returns the most recently started run, or "" if none.
+// b.mu must be held.
+func (b *Bridge) latestRunLocked() string {
+	var latest string
+	var started time.Time
+	for runID, run := range b.runs {
+		if latest == "" || run.started.After(started) {
+			latest, started = runID, run.started
+		}
+	}
+	return latest
+}
+
+func (b *Bridge) handleLogLevel(id int, level string) {
+	if level != "" {
+		if err := logging.SetLevel(b.logLevel, level); err != nil {
+			b.sendError(id, protocol.ErrInvalidParams, err.Error())
+			return
+		}
+		b.log.Info("log level changed", "level", b.logLevel.Level())
+	}
+	b.sendResult(id, protocol.LogLevelParams{Level: strings.ToLower(b.logLevel.Level().String())})
 }
 
 func (b *Bridge) handleStatus(id int) {
 	result := protocol.StatusResult{
 		Connected: b.client.IsConnected(),
-		SessionID: "", // TODO: track session ID
+		SessionID: b.currentSession(""),
 		Gateway:   b.config.Gateway.URL,
+		Pairing:   b.client.AwaitingPairing(),
+		Queued:    b.outbox.Len(),
+	}
+	if hb := b.client.Heartbeat(); !hb.LastBeat.IsZero() {
+		result.HeartbeatAgeMs = time.Since(hb.LastBeat).Milliseconds()
+		result.LatencyMs = hb.RTT.Milliseconds()
+	}
+	if hello := b.client.Hello(); hello != nil {
+		result.Protocol = hello.Protocol
+		result.ServerVersion = hello.Server.Version
 	}
 	b.sendResult(id, result)
 }
 
+// capabilities describes the current connection. Before the handshake
+// only the client version is known and every feature is reported
+// available.
+func (b *Bridge) capabilities() *protocol.CapabilitiesResult {
+	result := &protocol.CapabilitiesResult{
+		ClientVersion: Version,
+		Methods:       []string{},
+		Events:        []string{},
+		Features:      make(map[string]bool, len(gatewayMethods)),
+	}
+	for method, gatewayMethod := range gatewayMethods {
+		result.Features[method] = b.client.Supports(gatewayMethod)
+	}
+
+	hello := b.client.Hello()
+	if hello == nil {
+		return result
+	}
+	result.Protocol = hello.Protocol
+	result.ServerVersion = hello.Server.Version
+	if hello.Features.Methods != nil {
+		result.Methods = hello.Features.Methods
+	}
+	if hello.Features.Events != nil {
+		result.Events = hello.Features.Events
+	}
+	defaults := hello.Snapshot.SessionDefaults
+	result.SessionDefaults = protocol.SessionDefaults{
+		AgentID:    defaults.DefaultAgentID,
+		MainKey:    defaults.MainKey,
+		SessionKey: defaults.MainSessionKey,
+		Scope:      defaults.Scope,
+	}
+	return result
+}
+
 func (b *Bridge) handleReconnect(id int) {
-	if err := b.client.Reconnect(); err != nil {
-		b.sendError(id, protocol.ErrGatewayError, err.Error())
+	if err := b.client.Reconnect(b.ctx); err != nil {
+		b.sendGatewayError(id, err)
 		return
 	}
 	b.sendResult(id, map[string]string{"status": "reconnected"})
@@ -266,25 +777,265 @@ func (b *Bridge) handleSessionPath(id int) {
 	b.sendResult(id, map[string]string{"path": path})
 }
 
-func (b *Bridge) handleHistory(id int) {
-	// TODO: Implement actual history fetching from gateway
-	// For now, send notification with empty history
-	b.sendNotification("history", map[string]interface{}{
-		"messages": []interface{}{},
+func (b *Bridge) handleHistory(id int, params protocol.HistoryParams) {
+	if !b.client.IsConnected() {
+		b.sendError(id, protocol.ErrNotConnected, "not connected to gateway")
+		return
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	var before time.Time
+	if params.Before > 0 {
+		before = time.UnixMilli(params.Before)
+	}
+
+	entries, err := b.client.History(b.ctx, b.currentSession(params.SessionKey), limit, before)
+	if err != nil {
+		b.sendGatewayError(id, err)
+		return
+	}
+
+	messages := make([]protocol.HistoryMessage, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, protocol.HistoryMessage{
+			Role:      entry.Role,
+			Content:   entry.Text,
+			Timestamp: entry.Timestamp.UnixMilli(),
+		})
+	}
+
+	b.sendNotification("history", protocol.HistoryResult{Messages: messages})
+	b.sendResult(id, map[string]interface{}{"status": "ok", "count": len(messages)})
+}
+
+func (b *Bridge) handleSessionsList(id int) {
+	sessions, err := b.client.Sessions(b.ctx)
+	if err != nil {
+		b.sendGatewayError(id, err)
+		return
+	}
+
+	current := b.currentSession("")
+	result := protocol.SessionsResult{
+		Current:  current,
+		Sessions: make([]protocol.SessionInfo, 0, len(sessions)),
+	}
+	for _, s := range sessions {
+		info := protocol.SessionInfo{
+			Key:     s.Key,
+			Label:   s.Label,
+			Current: s.Key == current,
+		}
+		if !s.UpdatedAt.IsZero() {
+			info.UpdatedAt = s.UpdatedAt.UnixMilli()
+		}
+		result.Sessions = append(result.Sessions, info)
+	}
+	b.sendResult(id, result)
+}
+
+func (b *Bridge) handleSessionsCreate(id int, params protocol.SessionParams) {
+	if params.Key == "" {
+		b.sendError(id, protocol.ErrInvalidParams, "key is required")
+		return
+	}
+	if err := b.client.CreateSession(b.ctx, params.Key, params.Label); err != nil {
+		b.sendGatewayError(id, err)
+		return
+	}
+	b.switchSession(params.Key)
+	b.sendResult(id, map[string]string{"status": "created", "key": params.Key})
+}
+
+func (b *Bridge) handleSessionsSwitch(id int, key string) {
+	if key == "" {
+		b.sendError(id, protocol.ErrInvalidParams, "key is required")
+		return
+	}
+	b.switchSession(key)
+	b.sendResult(id, map[string]string{"status": "switched", "key": key})
+}
+
+func (b *Bridge) handleSessionsReset(id int, key string) {
+	key = b.currentSession(key)
+	if err := b.client.ResetSession(b.ctx, key); err != nil {
+		b.sendGatewayError(id, err)
+		return
+	}
+	b.sendResult(id, map[string]string{"status": "reset", "key": key})
+}
+
+// switchSession makes key the session used by requests that don't name one.
+func (b *Bridge) switchSession(key string) {
+	b.mu.Lock()
+	b.sessionKey = key
+	b.mu.Unlock()
+
+	b.sendNotification("session_changed", map[string]string{"key": key})
+}
+
+func (b *Bridge) handleRunEvent(ev openclaw.RunEvent) {
+	// Hold the lock while queueing output so a concurrent cancel can't put
+	// its aborted notification ahead of this run's last delta
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	runID := ev.Run()
+	run, ok := b.runs[runID]
+	if !ok {
+		// Cancelled, or started by someone else
+		return
+	}
+	b.progressLocked(runID, run, ev)
+
+	switch ev := ev.(type) {
+	case openclaw.Delta:
+		if err := b.transcript.Delta(runID, ev.Delta, time.Now()); err != nil {
+			b.log.Error("transcript", "err", err)
+		}
+		b.sendNotification("stream", protocol.StreamParams{
+			RunID: runID,
+			ID:    run.reqID,
+			Delta: ev.Delta,
+		})
+
+	case openclaw.Replace:
+		if err := b.transcript.Replace(runID, ev.Text, time.Now()); err != nil {
+			b.log.Error("transcript", "err", err)
+		}
+		b.sendNotification("replace", protocol.ReplaceParams{
+			RunID: runID,
+			ID:    run.reqID,
+			Text:  ev.Text,
+		})
+
+	case openclaw.Thinking:
+		b.sendNotification("thinking", protocol.ThinkingParams{
+			RunID: runID,
+			ID:    run.reqID,
+			Delta: ev.Delta,
+		})
+
+	case openclaw.ToolStart:
+		b.sendNotification("tool", protocol.ToolParams{
+			RunID:  runID,
+			ID:     run.reqID,
+			Phase:  "start",
+			Status: "running",
+			CallID: ev.CallID,
+			Name:   ev.Name,
+			Args:   ev.Args,
+		})
+
+	case openclaw.ToolResult:
+		status := "ok"
+		if ev.IsError {
+			status = "error"
+		}
+		b.sendNotification("tool", protocol.ToolParams{
+			RunID:      runID,
+			ID:         run.reqID,
+			Phase:      "result",
+			Status:     status,
+			CallID:     ev.CallID,
+			Name:       ev.Name,
+			Result:     ev.Result,
+			IsError:    ev.IsError,
+			DurationMs: ev.Duration.Milliseconds(),
+		})
+
+	case openclaw.Final:
+		b.finishRunLocked(runID)
+		params := protocol.FinalParams{
+			RunID:      runID,
+			ID:         run.reqID,
+			StopReason: ev.StopReason,
+		}
+		if u := ev.Usage; u != nil {
+			params.Usage = &protocol.Usage{
+				Input:      u.Input,
+				Output:     u.Output,
+				CacheRead:  u.CacheRead,
+				CacheWrite: u.CacheWrite,
+				Total:      u.Total,
+			}
+		}
+		b.sendNotification("final", params)
+		if !run.answered {
+			b.sendResult(run.reqID, map[string]string{"status": "ok"})
+		}
+
+	case openclaw.Error:
+		// The message goes to the editor as an error, never into the
+		// transcript as part of the answer
+		b.finishRunLocked(runID)
+		b.log.Warn("run failed", "run", runID, "code", ev.Code, "message", ev.Message)
+		b.sendNotification("run_error", protocol.RunErrorParams{
+			RunID:   runID,
+			ID:      run.reqID,
+			Code:    ev.Code,
+			Message: ev.Message,
+		})
+		if !run.answered {
+			resp := protocol.NewErrorResponse(run.reqID, protocol.ErrRunFailed, ev.Message)
+			resp.Error.Data = protocol.GatewayErrorData{
+				Method:  "chat.send",
+				Code:    ev.Code,
+				Message: ev.Message,
+			}
+			b.out.Write(resp)
+		}
+
+	case openclaw.Aborted:
+		b.finishRunLocked(runID)
+		b.sendNotification("aborted", protocol.AbortedParams{
+			RunID: runID,
+			ID:    run.reqID,
+		})
+		if !run.answered {
+			b.sendResult(run.reqID, map[string]string{"status": "aborted"})
+		}
+	}
+}
+
+// finishRunLocked forgets a run that ended and closes its transcript
+// block. b.mu must be held.
+func (b *Bridge) finishRunLocked(runID string) {
+	if run, ok := b.runs[runID]; ok {
+		run.unwatch()
+	}
+	delete(b.runs, runID)
+	if err := b.transcript.Finish(runID); err != nil {
+		b.log.Error("transcript", "err", err)
+	}
+}
+
+func (b *Bridge) handleGatewayConnected() {
+	// Notify nvim of connection (also sent after each automatic reconnect)
+	b.sendNotification("connected", protocol.ConnectedParams{
+		Gateway:      b.config.Gateway.URL,
+		Capabilities: b.capabilities(),
 	})
-	b.sendResult(id, map[string]string{"status": "ok"})
+	b.startFlush()
 }
 
-func (b *Bridge) handleGatewayMessage(content string, done bool) {
-	b.sendNotification("stream", protocol.StreamParams{
-		Delta: content,
-		Done:  done,
+func (b *Bridge) handleGatewayReconnecting(attempt int, delay time.Duration) {
+	b.sendNotification("reconnecting", protocol.ReconnectingParams{
+		Attempt: attempt,
+		DelayMs: delay.Milliseconds(),
+		Gateway: b.config.Gateway.URL,
 	})
+}
 
-	if done && b.reqID != 0 {
-		b.sendResult(b.reqID, map[string]string{"status": "ok"})
-		b.reqID = 0
-	}
+func (b *Bridge) handlePairingRequired(deviceID, fingerprint string) {
+	b.sendNotification("pairing_required", protocol.PairingParams{
+		DeviceID:    deviceID,
+		Fingerprint: fingerprint,
+		Gateway:     b.config.Gateway.URL,
+	})
 }
 
 func (b *Bridge) handleGatewayError(err error) {
@@ -295,19 +1046,72 @@ func (b *Bridge) handleGatewayError(err error) {
 
 func (b *Bridge) sendResult(id int, result interface{}) {
 	resp, _ := protocol.NewResponse(id, result)
-	b.encoder.Encode(resp)
+	b.out.Write(resp)
 }
 
 func (b *Bridge) sendError(id int, code int, message string) {
 	resp := protocol.NewErrorResponse(id, code, message)
-	b.encoder.Encode(resp)
+	b.out.Write(resp)
+}
+
+// sendGatewayError answers id with the error code matching a failed
+// gateway call.
+func (b *Bridge) sendGatewayError(id int, err error) {
+	var frameErr *openclaw.FrameError
+	switch {
+	case errors.As(err, &frameErr):
+		resp := protocol.NewErrorResponse(id, protocol.ErrGatewayError, err.Error())
+		resp.Error.Data = protocol.GatewayErrorData{
+			Method:  frameErr.Method,
+			Code:    frameErr.CodeString(),
+			Message: frameErr.Message,
+		}
+		b.out.Write(resp)
+	case errors.Is(err, openclaw.ErrNotConnected):
+		b.sendError(id, protocol.ErrNotConnected, "not connected to gateway")
+	case errors.Is(err, openclaw.ErrConnectionLost):
+		b.sendError(id, protocol.ErrConnectionLost, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		b.sendError(id, protocol.ErrTimeout, err.Error())
+	case errors.Is(err, openclaw.ErrPagingUnsupported):
+		b.sendError(id, protocol.ErrUnsupported, err.Error())
+	default:
+		b.sendError(id, protocol.ErrGatewayError, err.Error())
+	}
 }
 
 func (b *Bridge) sendNotification(method string, params interface{}) {
 	notif, _ := protocol.NewNotification(method, params)
-	b.encoder.Encode(notif)
+	b.out.Write(notif)
+}
+
+// spawn runs fn in a goroutine that Close waits for, unless the bridge
+// is already closing.
+func (b *Bridge) spawn(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ctx.Err() != nil {
+		return
+	}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn()
+	}()
 }
 
+// Close cancels outstanding gateway calls, waits for the goroutines
+// serving them and then shuts down. It is safe to call more than once.
 func (b *Bridge) Close() {
-	b.client.Close()
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		b.cancel()
+		b.mu.Unlock()
+
+		b.client.Close()
+		b.wg.Wait()
+		b.transcript.Close()
+		b.out.Close()
+		b.logFile.Close()
+	})
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/albxllm/moltstream/internal/protocol"
+)
+
+func TestDecodeParams(t *testing.T) {
+	tests := []struct {
+		params string
+		ok     bool
+		runID  string
+	}{
+		{``, true, ""},
+		{`null`, true, ""},
+		{`[]`, true, ""},
+		{`{}`, true, ""},
+		{`{"run_id":"molt-1"}`, true, "molt-1"},
+		{`["molt-1"]`, false, ""},
+		{`"molt-1"`, false, ""},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		b := &Bridge{out: protocol.NewWriter(&out, 1)}
+
+		var params protocol.CancelParams
+		req := &protocol.Request{Method: "cancel", Params: json.RawMessage(tt.params)}
+		ok := b.decodeParams(1, req, &params)
+		b.out.Close()
+
+		if ok != tt.ok || params.RunID != tt.runID {
+			t.Errorf("params %q: ok=%v run_id=%q, want ok=%v run_id=%q", tt.params, ok, params.RunID, tt.ok, tt.runID)
+		}
+		if answered := out.Len() > 0; answered == ok {
+			t.Errorf("params %q: answered=%v with ok=%v: %s", tt.params, answered, ok, out.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/protocol"
+	"github.com/albxllm/moltstream/internal/session"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// retryable reports whether a failed send may not have reached the
+// gateway, so it should be queued and sent again rather than reported.
+func retryable(err error) bool {
+	return errors.Is(err, openclaw.ErrNotConnected) ||
+		errors.Is(err, openclaw.ErrConnectionLost) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// queueLocked adds a send to the outbox and answers its request. The run
+// then starts when flushOutbox gets to it. b.mu must be held.
+func (b *Bridge) queueLocked(id int, entry session.OutboxEntry) {
+	if err := b.outbox.Add(entry); err != nil {
+		// Still queued in memory; it is only lost if the bridge exits
+		b.log.Error("outbox", "err", err)
+	}
+	b.queued[entry.RunID] = id
+	b.sendResult(id, protocol.SendResult{
+		Status: "queued",
+		RunID:  entry.RunID,
+		Queued: b.outbox.Len(),
+	})
+}
+
+// startFlush starts flushOutbox unless it is running, the outbox is
+// empty, or the gateway isn't connected.
+func (b *Bridge) startFlush() {
+	b.mu.Lock()
+	start := !b.flushing && b.outbox.Len() > 0 && b.client.IsConnected()
+	if start {
+		b.flushing = true
+	}
+	b.mu.Unlock()
+
+	if start {
+		b.spawn(b.flushOutbox)
+	}
+}
+
+// flushOutbox sends the queued messages in order, each with the runId it
+// was queued with, until the outbox is empty or the gateway is lost again.
+// A reconnect while it is on its way out finds it still running and leaves
+// the outbox to it, so it checks once more after it stops.
+func (b *Bridge) flushOutbox() {
+	for {
+		b.mu.Lock()
+		entry, ok := b.outbox.Peek()
+		if !ok || !b.client.IsConnected() || b.ctx.Err() != nil {
+			b.flushing = false
+			b.mu.Unlock()
+			b.startFlush()
+			return
+		}
+		reqID := b.queued[entry.RunID] // Zero if queued before a restart
+		run := &pendingRun{reqID: reqID, started: time.Now(), answered: true, sending: true}
+		b.runs[entry.RunID] = run
+		b.watchLocked(entry.RunID, run)
+		b.mu.Unlock()
+
+		b.log.Info("sending queued message", "run", entry.RunID, "queued_at", entry.QueuedAt)
+		err := b.client.Send(b.ctx, entry.RunID, entry.SessionKey, entry.Content)
+
+		b.mu.Lock()
+		cancelled := b.sentLocked(entry.RunID, run, err)
+		if err != nil && retryable(err) && !cancelled {
+			// Left at the head of the outbox for the next connect
+			run.unwatch()
+			delete(b.runs, entry.RunID)
+			b.flushing = false
+			b.mu.Unlock()
+			b.log.Warn("queued send failed", "run", entry.RunID, "err", err)
+			b.startFlush()
+			return
+		}
+
+		if _, err := b.outbox.Remove(entry.RunID); err != nil {
+			b.log.Error("outbox", "err", err)
+		}
+		delete(b.queued, entry.RunID)
+		if err != nil && !cancelled {
+			// Rejected for good; report it and go on with the rest
+			run.unwatch()
+			delete(b.runs, entry.RunID)
+			if err := b.transcript.Finish(entry.RunID); err != nil {
+				b.log.Error("transcript", "err", err)
+			}
+			b.sendNotification("run_error", protocol.RunErrorParams{
+				RunID:   entry.RunID,
+				ID:      reqID,
+				Code:    gatewayCode(err),
+				Message: err.Error(),
+			})
+		}
+		b.mu.Unlock()
+	}
+}
+
+// gatewayCode returns the code of a gateway error, if err is one.
+func gatewayCode(err error) string {
+	var frameErr *openclaw.FrameError
+	if errors.As(err, &frameErr) {
+		return frameErr.CodeString()
+	}
+	return ""
+}
+
+func (b *Bridge) handleQueueList(id int) {
+	entries := b.outbox.List()
+	result := protocol.QueueListResult{Entries: make([]protocol.QueueEntry, 0, len(entries))}
+	for _, e := range entries {
+		result.Entries = append(result.Entries, protocol.QueueEntry{
+			RunID:      e.RunID,
+			SessionKey: e.SessionKey,
+			Content:    e.Content,
+			QueuedAt:   e.QueuedAt.UnixMilli(),
+		})
+	}
+	b.sendResult(id, result)
+}
+
+// handleQueueDrop removes a queued message, or all of them if runID is
+// empty. A message already being handed to the gateway is kept; cancel
+// its run instead.
+func (b *Bridge) handleQueueDrop(id int, runID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if runID != "" && b.runs[runID] != nil {
+		b.sendError(id, protocol.ErrInvalidParams, "message is being sent")
+		return
+	}
+
+	var dropped int
+	for _, e := range b.outbox.List() {
+		if runID != "" && e.RunID != runID || b.runs[e.RunID] != nil {
+			continue
+		}
+		ok, err := b.outbox.Remove(e.RunID)
+		if err != nil {
+			b.log.Error("outbox", "err", err)
+		}
+		if ok {
+			dropped++
+			delete(b.queued, e.RunID)
+		}
+	}
+	if runID != "" && dropped == 0 {
+		b.sendError(id, protocol.ErrInvalidParams, "no queued message "+runID)
+		return
+	}
+	b.sendResult(id, protocol.QueueDropResult{Dropped: dropped})
+}
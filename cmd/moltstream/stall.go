@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/protocol"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// Stall timeouts used without run.first_token_timeout, run.idle_timeout
+// and run.tool_timeout in the config.
+const (
+	defaultFirstTokenTimeout = 2 * time.Minute
+	defaultIdleTimeout       = 90 * time.Second
+	defaultToolTimeout       = 10 * time.Minute
+)
+
+// stallTimeout returns how long run may go without an event before it
+// counts as stalled, or 0 if it may be quiet for as long as it likes.
+func (b *Bridge) stallTimeout(run *pendingRun) time.Duration {
+	switch {
+	case run.tools > 0:
+		// Tools report nothing until they finish, which can take a while
+		return b.config.Run.ToolTimeout
+	case run.lastSeen.IsZero():
+		return b.config.Run.FirstTokenTimeout
+	}
+	return b.config.Run.IdleTimeout
+}
+
+// watchLocked (re)starts the stall timer of a run that was just
+// registered or made progress. b.mu must be held.
+func (b *Bridge) watchLocked(runID string, run *pendingRun) {
+	timeout := b.stallTimeout(run)
+	switch {
+	case timeout <= 0:
+		run.unwatch()
+	case run.timer == nil:
+		run.timer = time.AfterFunc(timeout, func() { b.checkStall(runID, run) })
+	default:
+		run.timer.Reset(timeout)
+	}
+}
+
+// unwatch stops the stall timer of a run that ended or was dropped.
+func (r *pendingRun) unwatch() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// progressLocked records an event of a run for stall detection. b.mu must
+// be held.
+func (b *Bridge) progressLocked(runID string, run *pendingRun, ev openclaw.RunEvent) {
+	switch ev.(type) {
+	case openclaw.Final, openclaw.Error, openclaw.Aborted:
+		// Stopped by finishRunLocked
+		return
+	case openclaw.ToolStart:
+		run.tools++
+	case openclaw.ToolResult:
+		if run.tools > 0 {
+			run.tools--
+		}
+	}
+	run.lastSeen = time.Now()
+	if run.stalled {
+		// Reported once; whatever still comes is streamed as usual
+		return
+	}
+	b.watchLocked(runID, run)
+}
+
+// checkStall runs when the stall timer of a run fires. If the run still
+// made no progress it sends a stalled notification, answers the send with
+// ErrRunStalled, and aborts the run if run.abort_on_stall is set.
+// Otherwise the run stays tracked, without further stall checks, and text
+// that still comes is streamed as usual.
+func (b *Bridge) checkStall(runID string, run *pendingRun) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.runs[runID] != run || run.stalled || b.ctx.Err() != nil {
+		return
+	}
+	timeout := b.stallTimeout(run)
+	since, reason := run.lastSeen, "idle"
+	switch {
+	case run.tools > 0:
+		reason = "tool"
+	case since.IsZero():
+		since, reason = run.started, "first_token"
+	}
+	idle := time.Since(since)
+	if timeout <= 0 || idle < timeout {
+		// An event came in as the timer fired and restarted it
+		return
+	}
+
+	run.stalled = true
+	abort := b.config.Run.AbortOnStall
+	b.log.Warn("run stalled", "run", runID, "reason", reason, "idle", idle.Round(time.Millisecond), "abort", abort)
+
+	params := protocol.StalledParams{
+		RunID:   runID,
+		ID:      run.reqID,
+		Reason:  reason,
+		IdleMs:  idle.Milliseconds(),
+		Aborted: abort,
+	}
+	b.sendNotification("stalled", params)
+
+	// Write what the run has so far, so the transcript of later runs isn't
+	// held up behind it
+	if err := b.transcript.Finish(runID); err != nil {
+		b.log.Error("transcript", "err", err)
+	}
+
+	if abort {
+		delete(b.runs, runID)
+		b.abortLocked(runID, run)
+		b.sendNotification("aborted", protocol.AbortedParams{
+			RunID: runID,
+			ID:    run.reqID,
+		})
+	}
+
+	if !run.answered {
+		run.answered = true
+		var message string
+		switch reason {
+		case "first_token":
+			message = fmt.Sprintf("no response for %s", idle.Round(time.Millisecond))
+		case "tool":
+			message = fmt.Sprintf("no tool result for %s", idle.Round(time.Millisecond))
+		default:
+			message = fmt.Sprintf("response stalled for %s", idle.Round(time.Millisecond))
+		}
+		resp := protocol.NewErrorResponse(run.reqID, protocol.ErrRunStalled, message)
+		resp.Error.Data = params
+		b.out.Write(resp)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/internal/protocol"
+)
+
+// stallBridge returns a bridge whose runs of srv stall per the given
+// timeouts; a zero timeout is left long enough not to fire.
+func stallBridge(t *testing.T, srv *gatewaytest.Server, firstToken, idle, tool time.Duration, abort bool) *testBridge {
+	t.Helper()
+	long := func(d time.Duration) time.Duration {
+		if d == 0 {
+			return time.Minute
+		}
+		return d
+	}
+	return newTestBridge(t, srv, func(config *Config) {
+		config.Run.FirstTokenTimeout = long(firstToken)
+		config.Run.IdleTimeout = long(idle)
+		config.Run.ToolTimeout = long(tool)
+		config.Run.AbortOnStall = abort
+	})
+}
+
+// waitStalled reads up to the stalled notification of send id and the
+// ErrRunStalled answer to it. It returns the notification and the run
+// notification, if any, that ended the run in between.
+func waitStalled(t *testing.T, b *testBridge, id int) (params protocol.StalledParams, ended *message) {
+	t.Helper()
+	var notified, answered bool
+	for !notified || !answered {
+		msg := b.next()
+		switch {
+		case msg.Method == "stalled":
+			if err := json.Unmarshal(msg.Params, &params); err != nil || params.ID != id {
+				t.Fatalf("stalled %s, want send %d", msg.Params, id)
+			}
+			notified = true
+		case msg.ID != nil && *msg.ID == id:
+			if msg.Error == nil || msg.Error.Code != protocol.ErrRunStalled {
+				t.Fatalf("send: got %s %+v, want error %d", msg.Result, msg.Error, protocol.ErrRunStalled)
+			}
+			if !notified {
+				t.Fatal("send answered before the stalled notification")
+			}
+			var data protocol.StalledParams
+			raw, _ := json.Marshal(msg.Error.Data)
+			if err := json.Unmarshal(raw, &data); err != nil || data != params {
+				t.Errorf("error data %s, want %+v", raw, params)
+			}
+			if strings.Contains(msg.Error.Message, " 0s") {
+				t.Errorf("error message %q rounds the time away", msg.Error.Message)
+			}
+			answered = true
+		case msg.Method == "final" || msg.Method == "aborted":
+			if !notified {
+				t.Fatalf("run ended with %s before it stalled", msg.Method)
+			}
+			ended = &msg
+		}
+	}
+	return params, ended
+}
+
+func TestBridgeStallFirstToken(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(func(_, message string) []gatewaytest.Step {
+		return []gatewaytest.Step{{State: "final", Text: "late", Delay: 300 * time.Millisecond}}
+	})
+	b := stallBridge(t, srv, 50*time.Millisecond, 0, 0, false)
+
+	b.request(1, "send", protocol.SendParams{Content: "hi"})
+	p, _ := waitStalled(t, b, 1)
+	if p.Reason != "first_token" || p.IdleMs < 50 || p.Aborted {
+		t.Fatalf("got %+v", p)
+	}
+
+	// Without abort_on_stall the run goes on
+	msg := b.waitFor("final")
+	var rp runParams
+	json.Unmarshal(msg.Params, &rp)
+	if rp.ID != 1 || rp.RunID != p.RunID {
+		t.Fatalf("final %s, want run %s", msg.Params, p.RunID)
+	}
+	waitFrames(t, srv, "chat.send")
+}
+
+func TestBridgeStallIdle(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(func(_, message string) []gatewaytest.Step {
+		return []gatewaytest.Step{
+			{Text: "Let me"},
+			{State: "final", Text: "Let me think.", Delay: 300 * time.Millisecond},
+		}
+	})
+	b := stallBridge(t, srv, 0, 50*time.Millisecond, 0, false)
+
+	b.request(1, "send", protocol.SendParams{Content: "hi"})
+	p, _ := waitStalled(t, b, 1)
+	if p.Reason != "idle" || p.IdleMs < 50 || p.Aborted {
+		t.Fatalf("got %+v", p)
+	}
+	b.waitFor("final")
+}
+
+func TestBridgeStallTool(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(func(_, message string) []gatewaytest.Step {
+		return []gatewaytest.Step{
+			{Stream: "tool", Data: map[string]interface{}{"phase": "start", "name": "exec", "toolCallId": "call-1"}},
+			{Stream: "tool", Data: map[string]interface{}{"phase": "result", "name": "exec", "toolCallId": "call-1"}, Delay: 500 * time.Millisecond},
+			{State: "final", Text: "done"},
+		}
+	})
+	// The idle timeout doesn't apply while the tool runs, the tool
+	// timeout does
+	b := stallBridge(t, srv, 0, 20*time.Millisecond, 150*time.Millisecond, false)
+
+	b.request(1, "send", protocol.SendParams{Content: "hi"})
+	p, _ := waitStalled(t, b, 1)
+	if p.Reason != "tool" || p.IdleMs < 150 || p.Aborted {
+		t.Fatalf("got %+v", p)
+	}
+	b.waitFor("final")
+}
+
+func TestBridgeAbortOnStall(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(func(_, message string) []gatewaytest.Step {
+		return []gatewaytest.Step{{State: "final", Text: "never", Delay: time.Minute}}
+	})
+	b := stallBridge(t, srv, 50*time.Millisecond, 0, 0, true)
+
+	b.request(1, "send", protocol.SendParams{Content: "hi"})
+	p, ended := waitStalled(t, b, 1)
+	if p.Reason != "first_token" || !p.Aborted {
+		t.Fatalf("got %+v", p)
+	}
+	if ended == nil {
+		msg := b.waitFor("aborted")
+		ended = &msg
+	}
+	var rp runParams
+	json.Unmarshal(ended.Params, &rp)
+	if ended.Method != "aborted" || rp.ID != 1 || rp.RunID != p.RunID {
+		t.Fatalf("%s %s, want run %s aborted", ended.Method, ended.Params, p.RunID)
+	}
+	waitFrames(t, srv, "chat.send", "chat.abort")
+
+	// The bridge no longer tracks the run
+	b.request(2, "cancel", nil)
+	if resp := b.response(2); resp.Error == nil || resp.Error.Code != protocol.ErrNoActiveRun {
+		t.Fatalf("cancel after abort: got %s %+v", resp.Result, resp.Error)
+	}
+}
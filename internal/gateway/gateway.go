@@ -0,0 +1,103 @@
+// Package gateway adapts openclaw.Client to the callbacks the bridge is
+// built around.
+package gateway
+
+import (
+	"time"
+
+	"github.com/albxllm/moltstream/internal/logging"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// Client is an openclaw.Client whose events are dispatched to callbacks
+// from a single goroutine, in order.
+type Client struct {
+	*openclaw.Client
+
+	onRunEvent        func(ev openclaw.RunEvent)
+	onError           func(err error)
+	onConnected       func()
+	onReconnecting    func(attempt int, delay time.Duration)
+	onPairingRequired func(deviceID, fingerprint string)
+
+	dispatched chan struct{} // Closed when dispatch has drained Events
+}
+
+// NewClient creates a client for url that logs as the "gateway"
+// component. Register callbacks before calling Connect.
+func NewClient(url string, opts ...openclaw.Option) (*Client, error) {
+	opts = append([]openclaw.Option{openclaw.WithLogger(logging.Component("gateway"))}, opts...)
+	oc, err := openclaw.New(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{Client: oc, dispatched: make(chan struct{})}
+	go c.dispatch()
+	return c, nil
+}
+
+// OnRunEvent is called with each event of the runs started with Send:
+// openclaw.Delta, Replace, Final, Error, Aborted, ToolStart, ToolResult or
+// Thinking.
+func (c *Client) OnRunEvent(fn func(ev openclaw.RunEvent)) {
+	c.onRunEvent = fn
+}
+
+func (c *Client) OnError(fn func(err error)) {
+	c.onError = fn
+}
+
+// OnConnected is called each time the connect handshake completes,
+// including after an automatic reconnect.
+func (c *Client) OnConnected(fn func()) {
+	c.onConnected = fn
+}
+
+// OnReconnecting is called before each redial attempt with the delay
+// the client will wait first.
+func (c *Client) OnReconnecting(fn func(attempt int, delay time.Duration)) {
+	c.onReconnecting = fn
+}
+
+// OnPairingRequired is called when the gateway rejects connect because the
+// device isn't approved yet. The client keeps retrying until it is.
+func (c *Client) OnPairingRequired(fn func(deviceID, fingerprint string)) {
+	c.onPairingRequired = fn
+}
+
+// Close closes the client and waits for pending callbacks to return.
+func (c *Client) Close() error {
+	err := c.Client.Close()
+	<-c.dispatched
+	return err
+}
+
+func (c *Client) dispatch() {
+	defer close(c.dispatched)
+
+	for ev := range c.Events() {
+		switch ev := ev.(type) {
+		case openclaw.RunEvent:
+			if c.onRunEvent != nil {
+				c.onRunEvent(ev)
+			}
+		case openclaw.Connected:
+			if c.onConnected != nil {
+				c.onConnected()
+			}
+		case openclaw.Reconnecting:
+			if c.onReconnecting != nil {
+				c.onReconnecting(ev.Attempt, ev.Delay)
+			}
+		case openclaw.ConnectionError:
+			if c.onError != nil {
+				c.onError(ev.Err)
+			}
+		case openclaw.PairingRequired:
+			if c.onPairingRequired != nil {
+				c.onPairingRequired(ev.DeviceID, ev.Fingerprint)
+			}
+		}
+	}
+}
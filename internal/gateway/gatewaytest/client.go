@@ -0,0 +1,88 @@
+package gatewaytest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// IdentityFile writes a new device identity into a temporary directory
+// and returns its path, for openclaw.WithIdentityFile.
+func IdentityFile(tb testing.TB) string {
+	tb.Helper()
+	identity, err := openclaw.GenerateIdentity()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path := filepath.Join(tb.TempDir(), "device.json")
+	if err := openclaw.SaveIdentity(path, identity, false); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// Client returns a client connected to s with a fresh identity, after its
+// Connected event. opts are applied after the identity option. The client
+// is closed when the test ends.
+func (s *Server) Client(tb testing.TB, opts ...openclaw.Option) *openclaw.Client {
+	tb.Helper()
+	opts = append([]openclaw.Option{openclaw.WithIdentityFile(IdentityFile(tb))}, opts...)
+	c, err := openclaw.New(s.URL, opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Connect(ctx); err != nil {
+		tb.Fatal(err)
+	}
+	for {
+		select {
+		case ev := <-c.Events():
+			if _, ok := ev.(openclaw.Connected); ok {
+				return c
+			}
+		case <-ctx.Done():
+			tb.Fatal("no Connected event")
+		}
+	}
+}
+
+// NextEvent returns the next event of c, failing the test if none comes
+// within a few seconds.
+func NextEvent(tb testing.TB, c *openclaw.Client) openclaw.Event {
+	tb.Helper()
+	select {
+	case ev, ok := <-c.Events():
+		if !ok {
+			tb.Fatal("events closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		tb.Fatal("timed out waiting for an event")
+		return nil
+	}
+}
+
+// RunEvents returns the events of run runID up to the one that ends it,
+// skipping events of other runs and of the connection.
+func RunEvents(tb testing.TB, c *openclaw.Client, runID string) []openclaw.RunEvent {
+	tb.Helper()
+	var events []openclaw.RunEvent
+	for {
+		ev, ok := NextEvent(tb, c).(openclaw.RunEvent)
+		if !ok || ev.Run() != runID {
+			continue
+		}
+		events = append(events, ev)
+		switch ev.(type) {
+		case openclaw.Final, openclaw.Error, openclaw.Aborted:
+			return events
+		}
+	}
+}
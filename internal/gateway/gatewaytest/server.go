@@ -0,0 +1,755 @@
+// Package gatewaytest provides an in-process fake OpenClaw gateway for
+// tests and offline development.
+package gatewaytest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/albxllm/moltstream/pkg/openclaw"
+	"github.com/gorilla/websocket"
+)
+
+// Step is one scripted chat event. Text is the accumulated assistant text
+// at this point of the run, as the real gateway sends it.
+type Step struct {
+	State        string // "delta", "final", "error" or "aborted"; default "delta"
+	Text         string
+	Parts        []openclaw.ContentPart // Sent before the text part
+	ErrorCode    string
+	ErrorMessage string
+	StopReason   string          // Sent with "final"
+	Usage        *openclaw.Usage // Sent with "final"
+	Delay        time.Duration   // Wait before sending this step
+
+	// If Stream is set, the step is an "agent" event on that stream
+	// ("tool", "thinking", ...) with Data as its data, and State and the
+	// fields above are ignored.
+	Stream string
+	Data   interface{}
+}
+
+// ScriptFunc returns the events to stream for a chat.send.
+type ScriptFunc func(sessionKey, message string) []Step
+
+// HandlerFunc answers a req frame. A non-nil error is sent as ok: false.
+type HandlerFunc func(params json.RawMessage) (interface{}, *openclaw.FrameError)
+
+// Server is a fake openclaw. It issues connect.challenge, verifies the
+// device signature on connect, and streams scripted chat events.
+type Server struct {
+	URL   string // ws:// URL to pass to openclaw.New
+	Token string // Expected auth token; empty accepts any
+
+	// RequirePairing rejects connect with NOT_PAIRED until the device is
+	// approved with Approve.
+	RequirePairing bool
+
+	// Protocol is the version the server speaks (default 3). Methods is
+	// advertised in the hello; nil lists the built-in methods and any
+	// registered with Handle.
+	Protocol int
+	Methods  []string
+
+	// PageHistory makes chat.history honour "before". Like older gateways,
+	// the server ignores it by default and returns the newest messages.
+	PageHistory bool
+
+	http *httptest.Server
+
+	mu       sync.Mutex
+	conns    map[*conn]struct{}
+	script   ScriptFunc
+	handlers map[string]HandlerFunc
+	frames   []openclaw.GatewayFrame
+	stalled  chan struct{}               // Non-nil while Stall is in effect; closed by Resume
+	approved map[string]bool             // Device IDs allowed when RequirePairing is set
+	waiting  map[string]bool             // Device IDs rejected for lack of pairing
+	runs     map[string]chan struct{}    // Streaming runs; closed to abort
+	keys     map[string]string           // Status of each chat.send idempotency key seen
+	history  map[string][]historyMessage // Messages of each session, for chat.history
+}
+
+type conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	nonce   string
+
+	mu     sync.Mutex
+	authed bool
+}
+
+// historyMessage is a message of a session as chat.history returns it.
+type historyMessage struct {
+	Role      string                 `json:"role"`
+	Content   []openclaw.ContentPart `json:"content"`
+	Timestamp int64                  `json:"timestamp"` // Unix ms
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// NewServer starts a fake gateway on a loopback port. The default script
+// echoes the message back word by word.
+func NewServer() *Server {
+	s := newServer()
+	s.http = httptest.NewServer(http.HandlerFunc(s.serveWS))
+	s.URL = "ws" + strings.TrimPrefix(s.http.URL, "http")
+	return s
+}
+
+// NewTLSServer starts a fake gateway serving wss:// with a self-signed
+// certificate (see Certificate). cfg, if non-nil, is used as the server's
+// TLS config, e.g. to require client certificates.
+func NewTLSServer(cfg *tls.Config) *Server {
+	s := newServer()
+	s.http = httptest.NewUnstartedServer(http.HandlerFunc(s.serveWS))
+	if cfg != nil {
+		s.http.TLS = cfg
+	}
+	s.http.StartTLS()
+	s.URL = "wss" + strings.TrimPrefix(s.http.URL, "https")
+	return s
+}
+
+// NewUnixServer starts a fake gateway listening on the Unix socket at
+// path. Its URL is ws://gateway/ and only supplies the Host header.
+func NewUnixServer(path string) (*Server, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := newServer()
+	s.http = httptest.NewUnstartedServer(http.HandlerFunc(s.serveWS))
+	s.http.Listener.Close()
+	s.http.Listener = l
+	s.http.Start()
+	s.URL = "ws://gateway/"
+	return s, nil
+}
+
+func newServer() *Server {
+	return &Server{
+		conns:    make(map[*conn]struct{}),
+		script:   EchoScript,
+		handlers: make(map[string]HandlerFunc),
+		approved: make(map[string]bool),
+		waiting:  make(map[string]bool),
+		runs:     make(map[string]chan struct{}),
+		keys:     make(map[string]string),
+		history:  make(map[string][]historyMessage),
+	}
+}
+
+// Certificate returns the certificate of a TLS server, or nil.
+func (s *Server) Certificate() *x509.Certificate {
+	return s.http.Certificate()
+}
+
+// Close drops all connections, aborts streaming runs and stops the
+// server.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for runID, abort := range s.runs {
+		close(abort)
+		delete(s.runs, runID)
+	}
+	s.mu.Unlock()
+	s.Drop()
+	s.http.Close()
+}
+
+// Drop closes every open connection, as a network failure would.
+func (s *Server) Drop() {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.ws.Close()
+	}
+}
+
+// Stall stops answering pings and holds incoming frames, as with a
+// half-open TCP connection. Resume undoes it.
+func (s *Server) Stall() {
+	s.mu.Lock()
+	if s.stalled == nil {
+		s.stalled = make(chan struct{})
+	}
+	s.mu.Unlock()
+}
+
+func (s *Server) isStalled() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stalled
+}
+
+// Resume ends a Stall.
+func (s *Server) Resume() {
+	s.mu.Lock()
+	if s.stalled != nil {
+		close(s.stalled)
+		s.stalled = nil
+	}
+	s.mu.Unlock()
+}
+
+// Connections returns the number of open connections.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// SetScript replaces the script used for chat.send.
+func (s *Server) SetScript(fn ScriptFunc) {
+	s.mu.Lock()
+	s.script = fn
+	s.mu.Unlock()
+}
+
+// Handle answers method with fn instead of the built-in behavior.
+func (s *Server) Handle(method string, fn HandlerFunc) {
+	s.mu.Lock()
+	s.handlers[method] = fn
+	s.mu.Unlock()
+}
+
+// AddHistory appends a message to the history of a session, as if it had
+// been sent or answered at the given time.
+func (s *Server) AddHistory(sessionKey, role, text string, at time.Time) {
+	s.mu.Lock()
+	s.history[sessionKey] = append(s.history[sessionKey], historyMessage{
+		Role:      role,
+		Content:   []openclaw.ContentPart{{Type: "text", Text: text}},
+		Timestamp: at.UnixMilli(),
+	})
+	s.mu.Unlock()
+}
+
+// Approve pairs a device, so its next connect succeeds.
+func (s *Server) Approve(deviceID string) {
+	s.mu.Lock()
+	s.approved[deviceID] = true
+	delete(s.waiting, deviceID)
+	s.mu.Unlock()
+}
+
+// PendingDevices returns the devices that were refused for lack of pairing
+// and not approved since.
+func (s *Server) PendingDevices() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for id := range s.waiting {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Frames returns every req frame received so far.
+func (s *Server) Frames() []openclaw.GatewayFrame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]openclaw.GatewayFrame(nil), s.frames...)
+}
+
+// Broadcast sends an event frame to every authenticated connection.
+func (s *Server) Broadcast(event string, payload interface{}) {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.mu.Lock()
+		authed := c.authed
+		c.mu.Unlock()
+		if authed {
+			c.event(event, payload)
+		}
+	}
+}
+
+// EchoScript streams the message back one word at a time.
+func EchoScript(sessionKey, message string) []Step {
+	var steps []Step
+	var text string
+	for i, word := range strings.Fields(message) {
+		if i > 0 {
+			text += " "
+		}
+		text += word
+		steps = append(steps, Step{Text: text})
+	}
+	return append(steps, Step{State: "final", Text: text})
+}
+
+// Reply returns a script that streams the given chunks and then finishes.
+func Reply(chunks ...string) ScriptFunc {
+	return func(string, string) []Step {
+		var steps []Step
+		var text string
+		for _, chunk := range chunks {
+			text += chunk
+			steps = append(steps, Step{Text: text})
+		}
+		return append(steps, Step{State: "final", Text: text})
+	}
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &conn{
+		ws:    ws,
+		nonce: randomHex(16),
+	}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	c.event("connect.challenge", openclaw.ConnectChallenge{
+		Nonce: c.nonce,
+		Ts:    time.Now().UnixMilli(),
+	})
+
+	ws.SetPingHandler(func(data string) error {
+		if s.isStalled() != nil {
+			return nil
+		}
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		// Hold frames that arrive during a stall until Resume
+		if stalled := s.isStalled(); stalled != nil {
+			<-stalled
+		}
+
+		var frame openclaw.GatewayFrame
+		if err := json.Unmarshal(data, &frame); err != nil || frame.Type != "req" {
+			continue
+		}
+
+		s.mu.Lock()
+		s.frames = append(s.frames, frame)
+		handler := s.handlers[frame.Method]
+		s.mu.Unlock()
+
+		if frame.Method != "connect" {
+			c.mu.Lock()
+			authed := c.authed
+			c.mu.Unlock()
+			if !authed {
+				c.fail(frame.ID, "UNAUTHORIZED", "connect first")
+				continue
+			}
+		}
+
+		if handler != nil {
+			payload, ferr := handler(frame.Params)
+			if ferr != nil {
+				c.fail(frame.ID, ferr.Code, ferr.Message)
+			} else {
+				c.ok(frame.ID, payload)
+			}
+			continue
+		}
+
+		switch frame.Method {
+		case "connect":
+			s.handleConnect(c, &frame)
+		case "chat.send":
+			s.handleChatSend(c, &frame)
+		case "chat.abort":
+			s.handleChatAbort(c, &frame)
+		case "chat.history":
+			s.handleChatHistory(c, &frame)
+		default:
+			c.fail(frame.ID, "METHOD_NOT_FOUND", "unknown method "+frame.Method)
+		}
+	}
+}
+
+type connectParams struct {
+	MinProtocol int      `json:"minProtocol"`
+	MaxProtocol int      `json:"maxProtocol"`
+	Role        string   `json:"role"`
+	Scopes      []string `json:"scopes"`
+	Client      struct {
+		ID   string `json:"id"`
+		Mode string `json:"mode"`
+	} `json:"client"`
+	Auth struct {
+		Token string `json:"token"`
+	} `json:"auth"`
+	Device struct {
+		ID        string `json:"id"`
+		PublicKey string `json:"publicKey"`
+		Signature string `json:"signature"`
+		SignedAt  int64  `json:"signedAt"`
+		Nonce     string `json:"nonce"`
+	} `json:"device"`
+}
+
+func (s *Server) handleConnect(c *conn, frame *openclaw.GatewayFrame) {
+	var p connectParams
+	if err := json.Unmarshal(frame.Params, &p); err != nil {
+		c.fail(frame.ID, "INVALID_REQUEST", "invalid connect params")
+		return
+	}
+
+	s.mu.Lock()
+	protocol := s.Protocol
+	s.mu.Unlock()
+	if protocol == 0 {
+		protocol = 3
+	}
+	if protocol < p.MinProtocol || protocol > p.MaxProtocol {
+		c.fail(frame.ID, "PROTOCOL_MISMATCH",
+			fmt.Sprintf("server speaks protocol %d, client %d-%d", protocol, p.MinProtocol, p.MaxProtocol))
+		return
+	}
+
+	if s.Token != "" && p.Auth.Token != s.Token {
+		c.fail(frame.ID, "UNAUTHORIZED", "invalid token")
+		return
+	}
+
+	if err := verifyDevice(&p, c.nonce); err != nil {
+		c.fail(frame.ID, "INVALID_SIGNATURE", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	paired := !s.RequirePairing || s.approved[p.Device.ID]
+	if !paired {
+		s.waiting[p.Device.ID] = true
+	}
+	s.mu.Unlock()
+	if !paired {
+		c.fail(frame.ID, "NOT_PAIRED", "pairing required")
+		return
+	}
+
+	c.mu.Lock()
+	c.authed = true
+	c.mu.Unlock()
+
+	c.ok(frame.ID, map[string]interface{}{
+		"type":     "hello-ok",
+		"protocol": protocol,
+		"server": map[string]interface{}{
+			"version": "gatewaytest",
+			"host":    "localhost",
+		},
+		"features": map[string]interface{}{
+			"methods": s.methods(),
+			"events":  []string{"connect.challenge", "chat"},
+		},
+		"snapshot": map[string]interface{}{
+			"sessionDefaults": map[string]interface{}{
+				"defaultAgentId": "main",
+				"mainKey":        "main",
+				"mainSessionKey": "agent:main:main",
+			},
+		},
+	})
+}
+
+func (s *Server) methods() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Methods != nil {
+		return s.Methods
+	}
+	methods := []string{"connect", "chat.send", "chat.abort", "chat.history"}
+	for method := range s.handlers {
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// verifyDevice checks the v2 device signature the same way the gateway does.
+func verifyDevice(p *connectParams, nonce string) error {
+	if p.Device.Nonce != nonce {
+		return fmt.Errorf("nonce mismatch")
+	}
+
+	pub, err := base64.RawURLEncoding.DecodeString(p.Device.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+
+	if p.Device.ID != openclaw.DeviceIDFor(pub) {
+		return fmt.Errorf("device id does not match public key")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(p.Device.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding")
+	}
+
+	payload := fmt.Sprintf("v2|%s|%s|%s|%s|%s|%d|%s|%s",
+		p.Device.ID, p.Client.ID, p.Client.Mode, p.Role,
+		strings.Join(p.Scopes, ","), p.Device.SignedAt, p.Auth.Token, nonce)
+	if !ed25519.Verify(pub, []byte(payload), sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+type chatSendParams struct {
+	SessionKey     string `json:"sessionKey"`
+	Message        string `json:"message"`
+	IdempotencyKey string `json:"idempotencyKey"`
+}
+
+func (s *Server) handleChatSend(c *conn, frame *openclaw.GatewayFrame) {
+	var p chatSendParams
+	if err := json.Unmarshal(frame.Params, &p); err != nil || p.IdempotencyKey == "" {
+		c.fail(frame.ID, "INVALID_REQUEST", "invalid chat.send params")
+		return
+	}
+
+	// Like the real gateway, a repeated key doesn't start another run but
+	// reports how the first one is doing
+	runID := p.IdempotencyKey
+	s.mu.Lock()
+	script := s.script
+	status, dup := s.keys[runID]
+	abort := make(chan struct{})
+	if !dup {
+		s.keys[runID] = "in_flight"
+		s.runs[runID] = abort
+		s.history[p.SessionKey] = append(s.history[p.SessionKey], historyMessage{
+			Role:      "user",
+			Content:   []openclaw.ContentPart{{Type: "text", Text: p.Message}},
+			Timestamp: time.Now().UnixMilli(),
+		})
+	}
+	s.mu.Unlock()
+	if dup {
+		c.ok(frame.ID, map[string]interface{}{"runId": runID, "status": status})
+		return
+	}
+
+	c.ok(frame.ID, map[string]interface{}{"runId": runID, "status": "started"})
+
+	go s.stream(runID, p.SessionKey, script(p.SessionKey, p.Message), abort)
+}
+
+func (s *Server) handleChatAbort(c *conn, frame *openclaw.GatewayFrame) {
+	var p struct {
+		RunID string `json:"runId"`
+	}
+	json.Unmarshal(frame.Params, &p)
+
+	s.mu.Lock()
+	abort, ok := s.runs[p.RunID]
+	delete(s.runs, p.RunID)
+	s.mu.Unlock()
+
+	if ok {
+		close(abort)
+	}
+	c.ok(frame.ID, map[string]interface{}{"aborted": ok})
+}
+
+func (s *Server) handleChatHistory(c *conn, frame *openclaw.GatewayFrame) {
+	var p struct {
+		SessionKey string `json:"sessionKey"`
+		Limit      int    `json:"limit"`
+		Before     int64  `json:"before"`
+	}
+	json.Unmarshal(frame.Params, &p)
+
+	s.mu.Lock()
+	messages := append([]historyMessage{}, s.history[p.SessionKey]...)
+	s.mu.Unlock()
+	if s.PageHistory && p.Before > 0 {
+		n := 0
+		for n < len(messages) && messages[n].Timestamp < p.Before {
+			n++
+		}
+		messages = messages[:n]
+	}
+	if p.Limit > 0 && len(messages) > p.Limit {
+		messages = messages[len(messages)-p.Limit:]
+	}
+	c.ok(frame.ID, map[string]interface{}{"sessionKey": p.SessionKey, "messages": messages})
+}
+
+// stream broadcasts the scripted steps of a run with increasing seq. Like
+// the real gateway, the run goes on if the client that started it
+// disconnects; events sent meanwhile are lost to it.
+func (s *Server) stream(runID, sessionKey string, steps []Step, abort chan struct{}) {
+	status := "aborted"
+	var text string
+	defer func() {
+		s.mu.Lock()
+		delete(s.runs, runID)
+		s.keys[runID] = status
+		if status == "ok" {
+			s.history[sessionKey] = append(s.history[sessionKey], historyMessage{
+				Role:      "assistant",
+				Content:   []openclaw.ContentPart{{Type: "text", Text: text}},
+				Timestamp: time.Now().UnixMilli(),
+			})
+		}
+		s.mu.Unlock()
+	}()
+
+	seq := 0
+	for _, step := range steps {
+		if step.Delay > 0 {
+			select {
+			case <-time.After(step.Delay):
+			case <-abort:
+				s.Broadcast("chat", chatPayload(runID, sessionKey, seq+1, Step{State: "aborted", Text: text}))
+				return
+			}
+		}
+		select {
+		case <-abort:
+			s.Broadcast("chat", chatPayload(runID, sessionKey, seq+1, Step{State: "aborted", Text: text}))
+			return
+		default:
+		}
+
+		seq++
+		if step.Stream != "" {
+			s.Broadcast("agent", map[string]interface{}{
+				"runId":      runID,
+				"sessionKey": sessionKey,
+				"seq":        seq,
+				"stream":     step.Stream,
+				"data":       step.Data,
+			})
+			continue
+		}
+		if step.State == "" {
+			step.State = "delta"
+		}
+		text = step.Text
+		s.Broadcast("chat", chatPayload(runID, sessionKey, seq, step))
+		switch step.State {
+		case "final":
+			status = "ok"
+			return
+		case "error", "aborted":
+			status = step.State
+			return
+		}
+	}
+}
+
+func chatPayload(runID, sessionKey string, seq int, step Step) map[string]interface{} {
+	content := append([]openclaw.ContentPart{}, step.Parts...)
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": append(content, openclaw.ContentPart{Type: "text", Text: step.Text}),
+	}
+	if step.StopReason != "" {
+		message["stopReason"] = step.StopReason
+	}
+	if step.Usage != nil {
+		message["usage"] = step.Usage
+	}
+	payload := map[string]interface{}{
+		"runId":      runID,
+		"sessionKey": sessionKey,
+		"seq":        seq,
+		"state":      step.State,
+		"message":    message,
+	}
+	if step.ErrorCode != "" {
+		payload["errorCode"] = step.ErrorCode
+	}
+	if step.ErrorMessage != "" {
+		payload["errorMessage"] = step.ErrorMessage
+	}
+	return payload
+}
+
+func (c *conn) write(v interface{}) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.WriteJSON(v)
+}
+
+func (c *conn) event(event string, payload interface{}) {
+	c.write(map[string]interface{}{
+		"type":    "event",
+		"event":   event,
+		"payload": payload,
+	})
+}
+
+func (c *conn) ok(id string, payload interface{}) {
+	c.write(map[string]interface{}{
+		"type":    "res",
+		"id":      id,
+		"ok":      true,
+		"payload": payload,
+	})
+}
+
+func (c *conn) fail(id string, code interface{}, message string) {
+	c.write(map[string]interface{}{
+		"type":  "res",
+		"id":    id,
+		"ok":    false,
+		"error": openclaw.FrameError{Code: code, Message: message},
+	})
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
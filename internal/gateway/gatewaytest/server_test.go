@@ -0,0 +1,279 @@
+package gatewaytest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+func send(t *testing.T, c *openclaw.Client, message string) string {
+	t.Helper()
+	runID := openclaw.NewRunID()
+	if err := c.Send(context.Background(), runID, "main", message); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	return runID
+}
+
+func TestScriptedRun(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(gatewaytest.Reply("Hello", ", world"))
+	c := srv.Client(t)
+
+	runID := send(t, c, "hi")
+	events := gatewaytest.RunEvents(t, c, runID)
+
+	want := []openclaw.RunEvent{
+		openclaw.Delta{RunID: runID, Delta: "Hello", Text: "Hello"},
+		openclaw.Delta{RunID: runID, Delta: ", world", Text: "Hello, world"},
+		openclaw.Final{RunID: runID, Text: "Hello, world"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %+v, want %+v", len(events), events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	var sent bool
+	for _, frame := range srv.Frames() {
+		if frame.Method == "chat.send" && strings.Contains(string(frame.Params), `"idempotencyKey":"`+runID+`"`) {
+			sent = true
+		}
+	}
+	if !sent {
+		t.Error("no chat.send frame with the run ID as idempotency key")
+	}
+}
+
+func TestEchoScript(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	c := srv.Client(t)
+
+	runID := send(t, c, "one two  three")
+	events := gatewaytest.RunEvents(t, c, runID)
+	final, ok := events[len(events)-1].(openclaw.Final)
+	if !ok || final.Text != "one two three" {
+		t.Fatalf("got %+v, want final %q", events[len(events)-1], "one two three")
+	}
+	if len(events) != 4 {
+		t.Errorf("got %d events, want a delta per word and the final", len(events))
+	}
+}
+
+func TestRunError(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(func(string, string) []gatewaytest.Step {
+		return []gatewaytest.Step{
+			{Text: "partial"},
+			{State: "error", ErrorCode: "OVERLOADED", ErrorMessage: "model overloaded"},
+		}
+	})
+	c := srv.Client(t)
+
+	runID := send(t, c, "hi")
+	events := gatewaytest.RunEvents(t, c, runID)
+	got, ok := events[len(events)-1].(openclaw.Error)
+	want := openclaw.Error{RunID: runID, Code: "OVERLOADED", Message: "model overloaded", Text: "partial"}
+	if !ok || got != want {
+		t.Fatalf("got %+v, want %+v", events[len(events)-1], want)
+	}
+}
+
+func TestRejectedSend(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.Handle("chat.send", func(json.RawMessage) (interface{}, *openclaw.FrameError) {
+		return nil, &openclaw.FrameError{Code: "RATE_LIMITED", Message: "slow down"}
+	})
+	c := srv.Client(t)
+
+	err := c.Send(context.Background(), openclaw.NewRunID(), "main", "hi")
+	var frameErr *openclaw.FrameError
+	if !errors.As(err, &frameErr) || frameErr.CodeString() != "RATE_LIMITED" || frameErr.Method != "chat.send" {
+		t.Fatalf("got %v, want chat.send RATE_LIMITED", err)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(func(string, string) []gatewaytest.Step {
+		return []gatewaytest.Step{
+			{Text: "Hello"},
+			{Text: "Hello, world", Delay: time.Minute},
+			{State: "final", Text: "Hello, world"},
+		}
+	})
+	c := srv.Client(t)
+
+	runID := send(t, c, "hi")
+	if ev, ok := gatewaytest.NextEvent(t, c).(openclaw.Delta); !ok || ev.Text != "Hello" {
+		t.Fatalf("got %+v, want the first delta", ev)
+	}
+	partial, err := c.Abort(runID)
+	if err != nil || partial != "Hello" {
+		t.Fatalf("abort: %q, %v", partial, err)
+	}
+	if _, err := c.Abort(runID); !errors.Is(err, openclaw.ErrNoActiveRun) {
+		t.Errorf("second abort: got %v, want ErrNoActiveRun", err)
+	}
+
+	// A client repeating the send learns that the run was aborted, from
+	// the status of its idempotency key or, if the abort is still on its
+	// way, from the run's events; the run isn't started again
+	other := srv.Client(t)
+	if err := other.Send(context.Background(), runID, "main", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	events := gatewaytest.RunEvents(t, other, runID)
+	if _, ok := events[len(events)-1].(openclaw.Aborted); !ok {
+		t.Fatalf("got %+v, want the run aborted", events)
+	}
+}
+
+func TestReconnectAfterDrop(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	c := srv.Client(t, openclaw.WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+
+	srv.Drop()
+
+	var sawError, sawReconnecting bool
+	for {
+		switch ev := gatewaytest.NextEvent(t, c).(type) {
+		case openclaw.ConnectionError:
+			sawError = true
+		case openclaw.Reconnecting:
+			sawReconnecting = true
+			if ev.Attempt != 1 {
+				t.Errorf("first reconnect is attempt %d", ev.Attempt)
+			}
+		case openclaw.Connected:
+			if !sawError || !sawReconnecting {
+				t.Errorf("connected again without ConnectionError (%v) and Reconnecting (%v)", sawError, sawReconnecting)
+			}
+			if n := srv.Connections(); n != 1 {
+				t.Errorf("%d connections after reconnect", n)
+			}
+			runID := send(t, c, "still here")
+			gatewaytest.RunEvents(t, c, runID)
+			return
+		}
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	c := srv.Client(t,
+		openclaw.WithHeartbeat(20*time.Millisecond, 100*time.Millisecond),
+		openclaw.WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+
+	srv.Stall()
+	for {
+		ev, ok := gatewaytest.NextEvent(t, c).(openclaw.ConnectionError)
+		if ok {
+			if !strings.Contains(ev.Err.Error(), "heartbeat") {
+				t.Errorf("got %v, want a heartbeat timeout", ev.Err)
+			}
+			break
+		}
+	}
+	srv.Resume()
+	for {
+		if _, ok := gatewaytest.NextEvent(t, c).(openclaw.Connected); ok {
+			return
+		}
+	}
+}
+
+func TestWrongToken(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.Token = "secret"
+
+	c, err := openclaw.New(srv.URL, openclaw.WithIdentityFile(gatewaytest.IdentityFile(t)), openclaw.WithToken("guess"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ev, ok := gatewaytest.NextEvent(t, c).(openclaw.ConnectionError)
+	var frameErr *openclaw.FrameError
+	if !ok || !errors.As(ev.Err, &frameErr) || frameErr.CodeString() != "UNAUTHORIZED" {
+		t.Fatalf("got %+v, want UNAUTHORIZED", ev)
+	}
+	if c.IsConnected() {
+		t.Error("connected with a wrong token")
+	}
+}
+
+func TestPairing(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.RequirePairing = true
+
+	c, err := openclaw.New(srv.URL,
+		openclaw.WithIdentityFile(gatewaytest.IdentityFile(t)),
+		openclaw.WithPairingRetry(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ev, ok := gatewaytest.NextEvent(t, c).(openclaw.PairingRequired)
+	if !ok {
+		t.Fatalf("got %+v, want PairingRequired", ev)
+	}
+	if pending := srv.PendingDevices(); len(pending) != 1 || pending[0] != ev.DeviceID {
+		t.Fatalf("pending devices %v, want %s", pending, ev.DeviceID)
+	}
+
+	srv.Approve(ev.DeviceID)
+	for {
+		if _, ok := gatewaytest.NextEvent(t, c).(openclaw.Connected); ok {
+			break
+		}
+	}
+	if len(srv.PendingDevices()) != 0 {
+		t.Error("device still pending after approval")
+	}
+}
+
+func TestProtocolMismatch(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.Protocol = 99
+
+	c, err := openclaw.New(srv.URL, openclaw.WithIdentityFile(gatewaytest.IdentityFile(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ev, ok := gatewaytest.NextEvent(t, c).(openclaw.ConnectionError)
+	if !ok || !strings.Contains(ev.Err.Error(), "PROTOCOL_MISMATCH") {
+		t.Fatalf("got %+v, want PROTOCOL_MISMATCH", ev)
+	}
+}
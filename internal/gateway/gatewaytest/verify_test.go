@@ -0,0 +1,73 @@
+package gatewaytest
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// signedParams returns connect params signed the way the client signs
+// them.
+func signedParams(t *testing.T, nonce string) (*connectParams, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &connectParams{Role: "operator", Scopes: []string{"operator.read", "operator.write"}}
+	p.Client.ID = "cli"
+	p.Client.Mode = "cli"
+	p.Auth.Token = "token"
+	p.Device.ID = openclaw.DeviceIDFor(pub)
+	p.Device.PublicKey = base64.RawURLEncoding.EncodeToString(pub)
+	p.Device.SignedAt = 1700000000000
+	p.Device.Nonce = nonce
+	sign(p, priv)
+	return p, priv
+}
+
+func sign(p *connectParams, priv ed25519.PrivateKey) {
+	payload := fmt.Sprintf("v2|%s|%s|%s|%s|%s|%d|%s|%s",
+		p.Device.ID, p.Client.ID, p.Client.Mode, p.Role,
+		strings.Join(p.Scopes, ","), p.Device.SignedAt, p.Auth.Token, p.Device.Nonce)
+	p.Device.Signature = base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(payload)))
+}
+
+func TestVerifyDevice(t *testing.T) {
+	p, _ := signedParams(t, "abc")
+	if err := verifyDevice(p, "abc"); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(p *connectParams, priv ed25519.PrivateKey)
+		want   string
+	}{
+		{"other nonce", func(p *connectParams, _ ed25519.PrivateKey) { p.Device.Nonce = "xyz" }, "nonce"},
+		{"token changed after signing", func(p *connectParams, _ ed25519.PrivateKey) { p.Auth.Token = "other" }, "signature"},
+		{"scopes changed after signing", func(p *connectParams, _ ed25519.PrivateKey) { p.Scopes = []string{"operator.admin"} }, "signature"},
+		{"device id of another key", func(p *connectParams, priv ed25519.PrivateKey) {
+			other, _, _ := ed25519.GenerateKey(nil)
+			p.Device.ID = openclaw.DeviceIDFor(other)
+			sign(p, priv)
+		}, "device id"},
+		{"signed by another key", func(p *connectParams, _ ed25519.PrivateKey) {
+			_, other, _ := ed25519.GenerateKey(nil)
+			sign(p, other)
+		}, "signature"},
+		{"bad public key", func(p *connectParams, _ ed25519.PrivateKey) { p.Device.PublicKey = "AAAA" }, "public key"},
+	}
+	for _, tt := range tests {
+		p, priv := signedParams(t, "abc")
+		tt.tamper(p, priv)
+		err := verifyDevice(p, "abc")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error about %s", tt.name, err, tt.want)
+		}
+	}
+}
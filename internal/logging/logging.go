@@ -0,0 +1,173 @@
+// Package logging sets up leveled slog output to a size-rotated file, so
+// log lines stay out of the editor's stderr handler.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Options configures Setup.
+type Options struct {
+	Level        string // debug, info, warn or error
+	Path         string // Log file; empty logs to stderr
+	MaxSizeBytes int64  // Rotate once the file would grow past this
+	MaxBackups   int    // Rotated files to keep (path.1 … path.N)
+}
+
+// Setup installs a text handler on the default slog logger (which the
+// standard log package also writes through) and returns the level so it
+// can be changed at runtime. The returned closer closes the log file.
+func Setup(opts Options) (*slog.LevelVar, io.Closer, error) {
+	level := new(slog.LevelVar)
+	if err := SetLevel(level, opts.Level); err != nil {
+		return nil, nil, err
+	}
+
+	var out io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if opts.Path != "" {
+		file, err := OpenRotating(opts.Path, opts.MaxSizeBytes, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = file, file
+	}
+
+	handler := slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(handler))
+
+	return level, closer, nil
+}
+
+// SetLevel parses name ("debug", "info", "warn", "error") into level.
+// An empty name means info.
+func SetLevel(level *slog.LevelVar, name string) error {
+	if name == "" {
+		level.Set(slog.LevelInfo)
+		return nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return fmt.Errorf("invalid log level %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Component returns the default logger tagged with a component name.
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// RotatingFile is an append-only log file that is renamed to path.1 (and
+// older backups shifted up) once it reaches its size limit.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotating opens or creates path for appending. A maxBytes of zero
+// disables rotation.
+func OpenRotating(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+
+	r := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		// A failed rotation leaves the file open as it was; keep logging
+		// to it rather than losing everything after
+		if err := r.rotate(); err != nil && r.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// rotate moves the file to the first backup and starts a new one. If the
+// file can't be moved it is reopened to append to, and the error returned.
+func (r *RotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	var err error
+	if r.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if renameErr := os.Rename(r.path, r.path+".1"); renameErr != nil && !os.IsNotExist(renameErr) {
+			err = fmt.Errorf("rotate log file: %w", renameErr)
+		}
+	} else {
+		os.Remove(r.path)
+	}
+
+	if openErr := r.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
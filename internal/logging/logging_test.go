@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLines writes lines "line 0" to "line n-1", each padded to 10 bytes.
+func writeLines(t *testing.T, r *RotatingFile, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := fmt.Fprintf(r, "line %-4d\n", i); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "moltstream.log")
+	r, err := OpenRotating(path, 30, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, 10)
+	r.Close()
+
+	// Three lines per file; the oldest ones are gone past two backups
+	want := map[string]string{
+		path:        "line 9   \n",
+		path + ".1": "line 6   \nline 7   \nline 8   \n",
+		path + ".2": "line 3   \nline 4   \nline 5   \n",
+	}
+	for p, content := range want {
+		if got := readFile(t, p); got != content {
+			t.Errorf("%s: got %q, want %q", filepath.Base(p), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept a third backup: %v", err)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moltstream.log")
+	r, err := OpenRotating(path, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, 2)
+	r.Close()
+
+	// Reopened, the file counts what it already has
+	r, err = OpenRotating(path, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, 2)
+	r.Close()
+
+	if got := readFile(t, path); got != "line 1   \n" {
+		t.Errorf("got %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "line 0   \nline 1   \nline 0   \n" {
+		t.Errorf("backup: got %q", got)
+	}
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "moltstream.log")
+	r, err := OpenRotating(path, 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, 4)
+	r.Close()
+
+	if got := readFile(t, path); got != "line 3   \n" {
+		t.Errorf("got %q", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want only the log", len(entries))
+	}
+}
+
+func TestRotatingFileRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moltstream.log")
+	// A directory in the way of the backup
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRotating(path, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, 5)
+	r.Close()
+
+	if got := readFile(t, path); strings.Count(got, "\n") != 5 {
+		t.Errorf("got %q, want every line appended", got)
+	}
+	if _, err := r.Write([]byte("closed\n")); err != os.ErrClosed {
+		t.Errorf("write after Close: got %v", err)
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want slog.Level
+	}{
+		{"", slog.LevelInfo},
+		{"debug", slog.LevelDebug},
+		{"INFO", slog.LevelInfo},
+		{"warn", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+	for _, tt := range tests {
+		level := new(slog.LevelVar)
+		level.Set(slog.LevelError + 4)
+		if err := SetLevel(level, tt.name); err != nil || level.Level() != tt.want {
+			t.Errorf("%q: got %v, %v; want %v", tt.name, level.Level(), err, tt.want)
+		}
+	}
+
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+	if err := SetLevel(level, "loud"); err == nil || level.Level() != slog.LevelWarn {
+		t.Errorf("invalid level: got %v, level %v", err, level.Level())
+	}
+}
@@ -19,7 +19,16 @@ type Response struct {
 }
 
 type RPCError struct {
-	Code    int    `json:"code"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// GatewayErrorData is the data of an ErrGatewayError: what the gateway
+// answered to the request.
+type GatewayErrorData struct {
+	Method  string `json:"method,omitempty"`
+	Code    string `json:"code,omitempty"`
 	Message string `json:"message"`
 }
 
@@ -32,18 +41,225 @@ type Notification struct {
 // Application-specific params
 
 type SendParams struct {
-	Content string `json:"content"`
+	Content    string `json:"content"`
+	SessionKey string `json:"session_key,omitempty"` // Defaults to the current session
+}
+
+// SendResult answers a send that couldn't go out right away. The run
+// starts once the gateway is reachable; its notifications carry the id of
+// the send, but no further response follows.
+type SendResult struct {
+	Status string `json:"status"` // "queued"
+	RunID  string `json:"run_id"`
+	Queued int    `json:"queued"` // Messages in the outbox, including this one
 }
 
+type CancelParams struct {
+	RunID string `json:"run_id,omitempty"` // Defaults to the most recent run
+}
+
+// Run notifications carry the runId and the id of the send request that
+// started the run, so concurrent runs can be told apart. A run ends with
+// exactly one final, run_error or aborted notification.
+
+// StreamParams carries new answer text.
 type StreamParams struct {
+	RunID string `json:"run_id"`
+	ID    int    `json:"id"`
 	Delta string `json:"delta"`
-	Done  bool   `json:"done"`
+}
+
+// ReplaceParams carries the whole answer text so far, replacing what was
+// streamed, when the gateway revised text it had already sent.
+type ReplaceParams struct {
+	RunID string `json:"run_id"`
+	ID    int    `json:"id"`
+	Text  string `json:"text"`
+}
+
+type FinalParams struct {
+	RunID      string `json:"run_id"`
+	ID         int    `json:"id"`
+	StopReason string `json:"stop_reason,omitempty"`
+	Usage      *Usage `json:"usage,omitempty"`
+}
+
+type Usage struct {
+	Input      int `json:"input"`
+	Output     int `json:"output"`
+	CacheRead  int `json:"cache_read,omitempty"`
+	CacheWrite int `json:"cache_write,omitempty"`
+	Total      int `json:"total,omitempty"`
+}
+
+// RunErrorParams reports a run that failed on the gateway. Code is the
+// gateway's error code, if it sent one.
+type RunErrorParams struct {
+	RunID   string `json:"run_id"`
+	ID      int    `json:"id"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+type AbortedParams struct {
+	RunID string `json:"run_id"`
+	ID    int    `json:"id"`
+}
+
+// StalledParams reports a run that sent nothing for too long: no first
+// event within the first-token timeout ("first_token"), no further one
+// within the idle timeout ("idle"), or no tool result within the tool
+// timeout ("tool"). Aborted tells whether the bridge gave up on the run;
+// otherwise it may still go on streaming.
+type StalledParams struct {
+	RunID   string `json:"run_id"`
+	ID      int    `json:"id"`
+	Reason  string `json:"reason"`
+	IdleMs  int64  `json:"idle_ms"`
+	Aborted bool   `json:"aborted"`
+}
+
+// ToolParams reports a tool call of the agent. Phase is "start", with
+// Args and status "running", or "result", with Result, status "ok" or
+// "error", and how long the call took if its start was seen.
+type ToolParams struct {
+	RunID      string          `json:"run_id"`
+	ID         int             `json:"id"`
+	Phase      string          `json:"phase"`
+	Status     string          `json:"status"`
+	CallID     string          `json:"call_id"`
+	Name       string          `json:"name"`
+	Args       json.RawMessage `json:"args,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	IsError    bool            `json:"is_error,omitempty"`
+	DurationMs int64           `json:"duration_ms,omitempty"`
+}
+
+// ThinkingParams carries new reasoning text, which is not part of the
+// answer.
+type ThinkingParams struct {
+	RunID string `json:"run_id"`
+	ID    int    `json:"id"`
+	Delta string `json:"delta"`
+}
+
+type CancelResult struct {
+	Status  string `json:"status"`
+	RunID   string `json:"run_id"`
+	Partial string `json:"partial"`
+}
+
+// HistoryParams pages through gateway history. Before is a Unix ms
+// timestamp; pass the oldest timestamp already shown to load earlier messages.
+type HistoryParams struct {
+	SessionKey string `json:"session_key,omitempty"` // Defaults to the current session
+	Limit      int    `json:"limit,omitempty"`
+	Before     int64  `json:"before,omitempty"`
+}
+
+type HistoryMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Timestamp int64  `json:"timestamp"` // Unix ms
+}
+
+type HistoryResult struct {
+	Messages []HistoryMessage `json:"messages"`
+}
+
+type SessionParams struct {
+	Key   string `json:"key"`
+	Label string `json:"label,omitempty"`
+}
+
+type SessionInfo struct {
+	Key       string `json:"key"`
+	Label     string `json:"label,omitempty"`
+	UpdatedAt int64  `json:"updated_at,omitempty"` // Unix ms
+	Current   bool   `json:"current"`
+}
+
+type SessionsResult struct {
+	Current  string        `json:"current"`
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type QueueEntry struct {
+	RunID      string `json:"run_id"`
+	SessionKey string `json:"session_key"`
+	Content    string `json:"content"`
+	QueuedAt   int64  `json:"queued_at"` // Unix ms
+}
+
+type QueueListResult struct {
+	Entries []QueueEntry `json:"entries"`
+}
+
+// QueueDropParams names the queued message to drop; empty drops them all.
+type QueueDropParams struct {
+	RunID string `json:"run_id,omitempty"`
+}
+
+type QueueDropResult struct {
+	Dropped int `json:"dropped"`
+}
+
+// LogLevelParams sets the log level; an empty level just reports it.
+type LogLevelParams struct {
+	Level string `json:"level,omitempty"`
+}
+
+type PairingParams struct {
+	DeviceID    string `json:"device_id"`
+	Fingerprint string `json:"fingerprint"`
+	Gateway     string `json:"gateway"`
 }
 
 type StatusResult struct {
 	Connected bool   `json:"connected"`
 	SessionID string `json:"session_id"`
 	Gateway   string `json:"gateway"`
+	Pairing   bool   `json:"pairing,omitempty"` // Waiting for device approval
+	Queued    int    `json:"queued,omitempty"`  // Messages waiting in the outbox
+
+	// Omitted until the first heartbeat arrives
+	HeartbeatAgeMs int64 `json:"heartbeat_age_ms,omitempty"`
+	LatencyMs      int64 `json:"latency_ms,omitempty"`
+
+	// Omitted until the connect handshake completes
+	Protocol      int    `json:"protocol,omitempty"`
+	ServerVersion string `json:"server_version,omitempty"`
+}
+
+// CapabilitiesResult describes the connected gateway. Features maps each
+// bridge method backed by a gateway call to whether the gateway offers it,
+// so the editor can hide what won't work.
+type CapabilitiesResult struct {
+	Protocol        int             `json:"protocol"`
+	ServerVersion   string          `json:"server_version"`
+	ClientVersion   string          `json:"client_version"`
+	Methods         []string        `json:"methods"`
+	Events          []string        `json:"events"`
+	Features        map[string]bool `json:"features"`
+	SessionDefaults SessionDefaults `json:"session_defaults"`
+}
+
+type SessionDefaults struct {
+	AgentID    string `json:"agent_id,omitempty"`
+	MainKey    string `json:"main_key,omitempty"`
+	SessionKey string `json:"session_key,omitempty"`
+	Scope      string `json:"scope,omitempty"`
+}
+
+type ConnectedParams struct {
+	Gateway      string              `json:"gateway"`
+	Capabilities *CapabilitiesResult `json:"capabilities,omitempty"`
+}
+
+type ReconnectingParams struct {
+	Attempt int    `json:"attempt"`
+	DelayMs int64  `json:"delay_ms"`
+	Gateway string `json:"gateway"`
 }
 
 type ErrorResult struct {
@@ -102,11 +318,17 @@ func NewErrorResponse(id int, code int, message string) *Response {
 
 // Error codes
 const (
-	ErrParse       = -32700
-	ErrInvalidReq  = -32600
+	ErrParse          = -32700
+	ErrInvalidReq     = -32600
 	ErrMethodNotFound = -32601
 	ErrInvalidParams  = -32602
-	ErrInternal    = -32603
-	ErrNotConnected = -32000
-	ErrGatewayError = -32001
+	ErrInternal       = -32603
+	ErrNotConnected   = -32000
+	ErrGatewayError   = -32001
+	ErrNoActiveRun    = -32002
+	ErrUnsupported    = -32003 // Gateway doesn't offer the method
+	ErrTimeout        = -32004 // Gateway didn't answer in time
+	ErrConnectionLost = -32005 // Connection dropped before the gateway answered
+	ErrRunFailed      = -32006 // Gateway accepted the send but the run failed
+	ErrRunStalled     = -32007 // The run sent nothing for longer than the configured timeout
 )
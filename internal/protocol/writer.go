@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Writer serializes JSON-RPC messages onto a stream from a single
+// goroutine, so messages from different goroutines never interleave and
+// are written in the order Write was called.
+type Writer struct {
+	mu     sync.RWMutex // Guards closed against concurrent Write/Close
+	closed bool
+	queue  chan interface{}
+	done   chan struct{}
+	out    *bufio.Writer
+}
+
+// NewWriter starts a writer with room for buffer queued messages. Write
+// blocks while the queue is full.
+func NewWriter(w io.Writer, buffer int) *Writer {
+	wr := &Writer{
+		queue: make(chan interface{}, buffer),
+		done:  make(chan struct{}),
+		out:   bufio.NewWriter(w),
+	}
+	go wr.loop()
+	return wr
+}
+
+// Write queues msg to be encoded as one line. Messages written after
+// Close are dropped.
+func (w *Writer) Write(msg interface{}) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return
+	}
+	w.queue <- msg
+}
+
+// Close writes out everything queued and stops the writer.
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+func (w *Writer) loop() {
+	defer close(w.done)
+
+	for msg := range w.queue {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			slog.Error("encode message", "component", "bridge", "err", err)
+			continue
+		}
+		w.out.Write(append(line, '\n'))
+
+		// Batch writes while messages are queued, flush once idle
+		if len(w.queue) == 0 {
+			w.flush()
+		}
+	}
+	w.flush()
+}
+
+func (w *Writer) flush() {
+	if err := w.out.Flush(); err != nil {
+		slog.Error("write output", "component", "bridge", "err", err)
+	}
+}
@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+)
+
+type numbered struct {
+	G int `json:"g"` // Writing goroutine
+	N int `json:"n"` // Message number within it
+}
+
+// readLines decodes every line of r.
+func readLines(t *testing.T, r io.Reader) []numbered {
+	t.Helper()
+	var msgs []numbered
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var msg numbered
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestWriterConcurrentWrites(t *testing.T) {
+	const goroutines, each = 8, 500
+	var buf bytes.Buffer
+	w := NewWriter(&buf, 16)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < each; n++ {
+				w.Write(numbered{G: g, N: n})
+			}
+		}(g)
+	}
+	wg.Wait()
+	w.Close()
+
+	// Each line is whole, and each goroutine's messages are in order
+	msgs := readLines(t, &buf)
+	if len(msgs) != goroutines*each {
+		t.Fatalf("got %d messages, want %d", len(msgs), goroutines*each)
+	}
+	next := make([]int, goroutines)
+	for _, msg := range msgs {
+		if msg.N != next[msg.G] {
+			t.Fatalf("goroutine %d: got message %d, want %d", msg.G, msg.N, next[msg.G])
+		}
+		next[msg.G]++
+	}
+}
+
+// gatedWriter blocks writes until open is closed.
+type gatedWriter struct {
+	open chan struct{}
+	buf  bytes.Buffer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	<-g.open
+	return g.buf.Write(p)
+}
+
+func TestWriterCloseDrains(t *testing.T) {
+	const queued = 100
+	out := &gatedWriter{open: make(chan struct{})}
+	w := NewWriter(out, queued)
+	for n := 0; n < queued; n++ {
+		w.Write(numbered{N: n})
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		w.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the queue was written")
+	default:
+	}
+	close(out.open)
+	<-closed
+
+	// Written after Close, so dropped
+	w.Write(numbered{N: queued})
+
+	msgs := readLines(t, &out.buf)
+	if len(msgs) != queued {
+		t.Fatalf("got %d messages, want %d", len(msgs), queued)
+	}
+	for n, msg := range msgs {
+		if msg.N != n {
+			t.Fatalf("message %d: got %d", n, msg.N)
+		}
+	}
+}
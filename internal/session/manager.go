@@ -2,25 +2,26 @@ package session
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/albxllm/moltstream/internal/logging"
 )
 
 type Manager struct {
 	directory    string
 	maxSizeBytes int64
 	autoArchive  bool
+	log          *slog.Logger
 }
 
 func NewManager(directory string, maxSizeBytes int64, autoArchive bool) (*Manager, error) {
-	// Expand ~
-	if directory[:2] == "~/" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, err
-		}
-		directory = filepath.Join(home, directory[2:])
+	directory, err := ExpandPath(directory)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := os.MkdirAll(directory, 0755); err != nil {
@@ -36,9 +37,26 @@ func NewManager(directory string, maxSizeBytes int64, autoArchive bool) (*Manage
 		directory:    directory,
 		maxSizeBytes: maxSizeBytes,
 		autoArchive:  autoArchive,
+		log:          logging.Component("session"),
 	}, nil
 }
 
+// ExpandPath replaces a leading "~/" with the user's home directory.
+func ExpandPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+func (m *Manager) Directory() string {
+	return m.directory
+}
+
 func (m *Manager) SessionPath() string {
 	return filepath.Join(m.directory, "session.md")
 }
@@ -47,6 +65,11 @@ func (m *Manager) ArchiveDir() string {
 	return filepath.Join(m.directory, "archive")
 }
 
+// OutboxPath is where messages waiting for the gateway are kept.
+func (m *Manager) OutboxPath() string {
+	return filepath.Join(m.directory, "outbox.json")
+}
+
 func (m *Manager) EnsureSession() (string, error) {
 	path := m.SessionPath()
 
@@ -58,6 +81,7 @@ func (m *Manager) EnsureSession() (string, error) {
 	if m.autoArchive {
 		info, err := os.Stat(path)
 		if err == nil && info.Size() > m.maxSizeBytes {
+			m.log.Info("session file over size limit, archiving", "size", info.Size(), "max", m.maxSizeBytes)
 			if err := m.Archive(); err != nil {
 				return "", fmt.Errorf("auto-archive: %w", err)
 			}
@@ -89,7 +113,11 @@ func (m *Manager) Archive() error {
 	timestamp := time.Now().Format("2006-01-02-150405")
 	dst := filepath.Join(m.ArchiveDir(), fmt.Sprintf("session-%s.md", timestamp))
 
-	return os.Rename(src, dst)
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	m.log.Info("archived session", "path", dst)
+	return nil
 }
 
 func (m *Manager) GetSize() (int64, error) {
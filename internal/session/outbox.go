@@ -0,0 +1,136 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outbox holds messages sent while the gateway was unreachable, in the
+// order they were sent. It is saved to a file on every change so queued
+// messages survive a restart of the bridge.
+type Outbox struct {
+	path string
+
+	mu      sync.Mutex
+	entries []OutboxEntry
+}
+
+// OutboxEntry is a queued message. RunID is the idempotency key it is
+// sent with, kept across retries so the gateway can tell them apart from
+// new messages.
+type OutboxEntry struct {
+	RunID      string    `json:"run_id"`
+	SessionKey string    `json:"session_key"`
+	Content    string    `json:"content"`
+	QueuedAt   time.Time `json:"queued_at"`
+}
+
+// OpenOutbox loads the outbox stored at path. A missing file is an empty
+// outbox.
+func OpenOutbox(path string) (*Outbox, error) {
+	o := &Outbox{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read outbox: %w", err)
+	}
+	if err := json.Unmarshal(data, &o.entries); err != nil {
+		return nil, fmt.Errorf("parse outbox %s: %w", path, err)
+	}
+	return o, nil
+}
+
+// Add queues a message at the end.
+func (o *Outbox) Add(entry OutboxEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = append(o.entries, entry)
+	return o.saveLocked()
+}
+
+// Peek returns the oldest queued message.
+func (o *Outbox) Peek() (OutboxEntry, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.entries) == 0 {
+		return OutboxEntry{}, false
+	}
+	return o.entries[0], true
+}
+
+// List returns the queued messages, oldest first.
+func (o *Outbox) List() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]OutboxEntry(nil), o.entries...)
+}
+
+// Len returns the number of queued messages.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Remove drops the message with the given run ID and reports whether it
+// was queued.
+func (o *Outbox) Remove(runID string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, e := range o.entries {
+		if e.RunID == runID {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			return true, o.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// saveLocked replaces the file with the current entries, or removes it
+// when there are none. The messages may be private, so the file is only
+// readable by the user. o.mu must be held.
+func (o *Outbox) saveLocked() error {
+	if len(o.entries) == 0 {
+		if err := os.Remove(o.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("save outbox: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(o.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file and rename it over the old one, so a crash
+	// leaves either version intact
+	tmp := o.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("save outbox: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("save outbox: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("save outbox: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("save outbox: %w", err)
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return fmt.Errorf("save outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Message is one entry of a session file.
+type Message struct {
+	Role  string    // Heading name, e.g. "User" or "Assistant"
+	Clock string    // HH:MM as written in the heading
+	Time  time.Time // Clock resolved against the session's created date
+	Body  string    // Text without the trailing blank lines
+}
+
+// File is a parsed session or archive file.
+type File struct {
+	ID       string
+	Created  time.Time
+	Messages []Message
+}
+
+var (
+	headerRE  = regexp.MustCompile(`^<!--\s*(\w+):\s*(.*?)\s*-->$`)
+	headingRE = regexp.MustCompile(`^## (\w[\w ]*) \[(\d{1,2}):(\d{2})\]$`)
+)
+
+// ParseFile parses the session file at path.
+func ParseFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	file, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Parse reads the session format written by Transcript. A message starts
+// at a "---" line followed by a blank line and a role heading; separators
+// and headings inside fenced code blocks are part of the body. Fences are
+// tracked per message, so one that is never closed, as left by a run that
+// ended mid-block, is taken as text instead of swallowing the messages
+// after it.
+//
+// Headings only carry the time of day, so Time uses the created date and
+// moves to the next day whenever the clock goes backwards.
+func Parse(r io.Reader) (*File, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	starts := messageStarts(lines)
+	file := &File{}
+	var (
+		current *Message
+		body    []string
+		day     time.Time
+		last    time.Time
+	)
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		current.Body = strings.TrimRight(strings.Join(body, "\n"), "\n")
+		file.Messages = append(file.Messages, *current)
+		current, body = nil, nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if starts[i] {
+			if m := headingRE.FindStringSubmatch(lines[i+2]); m != nil {
+				finish()
+
+				if day.IsZero() {
+					day = file.Created
+					if day.IsZero() {
+						day = time.Now()
+					}
+				}
+				var hour, minute int
+				fmt.Sscanf(m[2], "%d", &hour)
+				fmt.Sscanf(m[3], "%d", &minute)
+				at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+				if at.Before(last) {
+					at = at.AddDate(0, 0, 1)
+					day = at
+				}
+				last = at
+
+				current = &Message{
+					Role:  m[1],
+					Clock: fmt.Sprintf("%02d:%02d", hour, minute),
+					Time:  at,
+				}
+				i += 2
+				// Skip the blank line between heading and body
+				if i+1 < len(lines) && lines[i+1] == "" {
+					i++
+				}
+				continue
+			}
+		}
+
+		if current == nil {
+			parseHeader(file, line)
+			continue
+		}
+
+		body = append(body, line)
+	}
+	finish()
+
+	return file, nil
+}
+
+func parseHeader(file *File, line string) {
+	m := headerRE.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return
+	}
+	switch m[1] {
+	case "id":
+		file.ID = m[2]
+	case "created":
+		if t, err := time.Parse(time.RFC3339, m[2]); err == nil {
+			file.Created = t.Local()
+		}
+	}
+}
+
+// isHeading reports whether lines[i] is a "---" line followed by a blank
+// line and a role heading.
+func isHeading(lines []string, i int) bool {
+	return lines[i] == "---" && i+2 < len(lines) && lines[i+1] == "" && headingRE.MatchString(lines[i+2])
+}
+
+// messageStarts returns the heading lines that start a message, leaving
+// out those inside fenced code blocks.
+func messageStarts(lines []string) map[int]bool {
+	var headings []int
+	for i := range lines {
+		if isHeading(lines, i) {
+			headings = append(headings, i)
+		}
+	}
+	headings = append(headings, len(lines))
+
+	starts := make(map[int]bool)
+	for h := 0; h < len(headings)-1; {
+		starts[headings[h]] = true
+		h = messageEnd(lines, headings, h)
+	}
+	return starts
+}
+
+// messageEnd returns the index in headings of the heading after the message
+// that starts at headings[h]. A fence still open at a heading only runs
+// past it if it closes in a later part that leaves a fence open when read
+// on its own, the way a quoted message split off a code block does.
+// Otherwise the fence is never closed within the message, so the line that
+// opened it is taken as text and the message is scanned again.
+func messageEnd(lines []string, headings []int, h int) int {
+	unclosed := make(map[int]bool)
+	for {
+		var fence string
+		opened, next := -1, h+1
+		for i := headings[h]; i < len(lines); i++ {
+			if i == headings[next] {
+				if fence == "" {
+					return next
+				}
+				m := closingPart(lines, headings, next, i, fence)
+				if m < 0 {
+					break
+				}
+				next = m + 1
+			}
+			if unclosed[i] {
+				continue
+			}
+			if n := nextFence(fence, lines[i]); n != fence {
+				if fence == "" {
+					opened = i
+				}
+				fence = n
+			}
+		}
+		if fence == "" {
+			return next
+		}
+		unclosed[opened] = true
+	}
+}
+
+// closingPart returns the index in headings of the part, from headings[h]
+// on, whose lines close fence, or -1 if there is none or that part leaves a
+// fence open when read on its own.
+func closingPart(lines []string, headings []int, h, from int, fence string) int {
+	end := from
+	for end < len(lines) && nextFence(fence, lines[end]) != "" {
+		end++
+	}
+	if end == len(lines) {
+		return -1
+	}
+	for headings[h+1] <= end {
+		h++
+	}
+
+	var own string
+	for _, line := range lines[headings[h]:headings[h+1]] {
+		own = nextFence(own, line)
+	}
+	if own == "" {
+		return -1
+	}
+	return h
+}
+
+// nextFence tracks fenced code blocks. open is the marker of the enclosing
+// fence ("" outside one); the result is the marker after line.
+func nextFence(open, line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return open // Indented code, not a fence
+	}
+
+	marker := fenceMarker(trimmed)
+	if marker == "" {
+		return open
+	}
+	if open == "" {
+		return marker
+	}
+	// A closing fence uses the same character, is at least as long and
+	// has nothing after it
+	if marker[0] == open[0] && len(marker) >= len(open) && strings.TrimSpace(trimmed[len(marker):]) == "" {
+		return ""
+	}
+	return open
+}
+
+func fenceMarker(s string) string {
+	if s == "" || (s[0] != '`' && s[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(s) && s[n] == s[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return s[:n]
+}
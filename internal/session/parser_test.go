@@ -0,0 +1,109 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	input := `<!-- moltstream session -->
+<!-- id: 42 -->
+<!-- created: 2026-03-01T22:00:00Z -->
+
+---
+
+## User [23:58]
+
+Show me a session file
+
+---
+
+## Assistant [23:59]
+
+Like this:
+
+` + "```markdown" + `
+---
+
+## User [12:00]
+
+not a message
+` + "```" + `
+
+---
+
+## User [00:01]
+
+thanks
+`
+	file, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.ID != "42" {
+		t.Errorf("id %q", file.ID)
+	}
+	if len(file.Messages) != 3 {
+		t.Fatalf("got %d messages: %+v", len(file.Messages), file.Messages)
+	}
+
+	reply := file.Messages[1]
+	if reply.Role != "Assistant" || !strings.Contains(reply.Body, "not a message") || !strings.HasSuffix(reply.Body, "```") {
+		t.Errorf("fenced separator not kept in the body: %+v", reply)
+	}
+
+	last := file.Messages[2]
+	if last.Role != "User" || last.Body != "thanks" || last.Clock != "00:01" {
+		t.Errorf("got %+v", last)
+	}
+	created := file.Created
+	want := time.Date(created.Year(), created.Month(), created.Day()+1, 0, 1, 0, 0, created.Location())
+	if !last.Time.Equal(want) {
+		t.Errorf("time %v, want %v the next day", last.Time, want)
+	}
+}
+
+func TestParseUnclosedFence(t *testing.T) {
+	input := "---\n\n## Assistant [10:00]\n\n```go\nfunc main() {\n\n" +
+		"---\n\n## User [10:05]\n\nstill there?\n\n" +
+		"---\n\n## Assistant [10:05]\n\nyes\n\n"
+	file, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roles []string
+	for _, m := range file.Messages {
+		roles = append(roles, m.Role)
+	}
+	if strings.Join(roles, ",") != "Assistant,User,Assistant" {
+		t.Fatalf("got messages %v, want the unclosed fence to end at the next message", roles)
+	}
+	if file.Messages[1].Body != "still there?" || file.Messages[2].Body != "yes" {
+		t.Errorf("got %+v", file.Messages)
+	}
+}
+
+func TestParseUnclosedFenceBeforeCode(t *testing.T) {
+	// The bare fence closing the later code block must not pair with the
+	// unclosed one
+	input := "---\n\n## User [10:00]\n\n```\npanic: nil map\n\n" +
+		"---\n\n## Assistant [10:01]\n\n```go\nm := make(map[string]int)\n```\n\n" +
+		"---\n\n## User [10:02]\n\nthanks\n\n"
+	file, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roles []string
+	for _, m := range file.Messages {
+		roles = append(roles, m.Role)
+	}
+	if strings.Join(roles, ",") != "User,Assistant,User" {
+		t.Fatalf("got messages %v, want fences tracked per message", roles)
+	}
+	if file.Messages[1].Body != "```go\nm := make(map[string]int)\n```" || file.Messages[2].Body != "thanks" {
+		t.Errorf("got %+v", file.Messages)
+	}
+}
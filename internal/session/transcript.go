@@ -0,0 +1,229 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcript appends messages to the session file in the documented
+// format. Assistant text is written as deltas arrive so a crash loses at
+// most the unsynced tail.
+//
+// Only one block can be open at the end of the file. When runs overlap,
+// later blocks are buffered and written in order once the open one is done.
+// A run that may never finish, such as a stalled one, should be finished
+// early so it doesn't hold up the rest; text that still comes for it goes
+// into a new block.
+type Transcript struct {
+	manager *Manager
+
+	mu    sync.Mutex
+	file  *os.File
+	queue []*block          // queue[0] may be partially written
+	runs  map[string]*block // Assistant blocks still streaming, keyed by runId
+}
+
+type block struct {
+	role    string
+	at      time.Time
+	text    string
+	written int   // Bytes of text already in the file
+	offset  int64 // File size where the text starts, once started
+	started bool  // Heading written
+	done    bool
+}
+
+func NewTranscript(manager *Manager) *Transcript {
+	return &Transcript{
+		manager: manager,
+		runs:    make(map[string]*block),
+	}
+}
+
+// User records a message sent by the user.
+func (t *Transcript) User(text string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &block{role: "User", at: at, text: text}
+	b.finish()
+	t.queue = append(t.queue, b)
+	return t.flushLocked()
+}
+
+// Delta appends streamed assistant text for a run.
+func (t *Transcript) Delta(runID, delta string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.runs[runID]
+	if !ok {
+		b = &block{role: "Assistant", at: at}
+		t.runs[runID] = b
+		t.queue = append(t.queue, b)
+	}
+	b.text += delta
+	return t.flushLocked()
+}
+
+// Replace sets the whole streamed text of a run, for when the gateway
+// revised text it already sent. If the revision changes what is already
+// in the file, the block is truncated and rewritten; it is always the
+// last one in the file while it streams.
+func (t *Transcript) Replace(runID, text string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.runs[runID]
+	if !ok {
+		b = &block{role: "Assistant", at: at}
+		t.runs[runID] = b
+		t.queue = append(t.queue, b)
+	}
+	if b.started && t.file != nil && !strings.HasPrefix(text, b.text[:b.written]) {
+		if err := t.file.Truncate(b.offset); err != nil {
+			return fmt.Errorf("rewrite transcript: %w", err)
+		}
+		b.written = 0
+	}
+	b.text = text
+	return t.flushLocked()
+}
+
+// Finish closes the assistant block of a run and syncs the file.
+func (t *Transcript) Finish(runID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.runs[runID]
+	if !ok {
+		return nil
+	}
+	delete(t.runs, runID)
+	b.finish()
+
+	if err := t.flushLocked(); err != nil {
+		return err
+	}
+	if t.file == nil {
+		return nil
+	}
+	return t.file.Sync()
+}
+
+// Close finishes the blocks still streaming with the text they have so
+// far, writes everything queued behind them, and closes the session file.
+func (t *Transcript) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for runID, b := range t.runs {
+		b.finish()
+		delete(t.runs, runID)
+	}
+	flushErr := t.flushLocked()
+
+	if t.file == nil {
+		return flushErr
+	}
+	t.file.Sync()
+	err := t.file.Close()
+	if flushErr != nil {
+		err = flushErr
+	}
+	t.file = nil
+	return err
+}
+
+// finish marks a block done, closing a code block its text left open, as
+// happens when a run ends early or a user message is cut off. Parse would
+// otherwise have to guess where the block ends.
+func (b *block) finish() {
+	b.done = true
+
+	var fence string
+	for _, line := range strings.Split(b.text, "\n") {
+		fence = nextFence(fence, line)
+	}
+	if fence == "" {
+		return
+	}
+	if !strings.HasSuffix(b.text, "\n") {
+		b.text += "\n"
+	}
+	b.text += fence + "\n"
+}
+
+// flushLocked writes queued blocks until it reaches one that is still
+// streaming. t.mu must be held.
+func (t *Transcript) flushLocked() error {
+	for len(t.queue) > 0 {
+		b := t.queue[0]
+
+		var buf strings.Builder
+		if !b.started {
+			// Rotation only happens between blocks, never mid-message
+			if err := t.openLocked(); err != nil {
+				return err
+			}
+			fmt.Fprintf(&buf, "---\n\n## %s [%s]\n\n", b.role, b.at.Format("15:04"))
+			info, err := t.file.Stat()
+			if err != nil {
+				return fmt.Errorf("write transcript: %w", err)
+			}
+			b.offset = info.Size() + int64(buf.Len())
+		}
+		buf.WriteString(b.text[b.written:])
+		if b.done && b.text != "" {
+			if strings.HasSuffix(b.text, "\n") {
+				buf.WriteString("\n")
+			} else {
+				buf.WriteString("\n\n")
+			}
+		}
+
+		if buf.Len() > 0 {
+			if _, err := t.file.WriteString(buf.String()); err != nil {
+				return fmt.Errorf("write transcript: %w", err)
+			}
+		}
+		b.started = true
+		b.written = len(b.text)
+
+		if !b.done {
+			return nil
+		}
+		t.queue = t.queue[1:]
+	}
+	return nil
+}
+
+// openLocked makes sure t.file is the current session file, reopening it
+// if EnsureSession created or archived it since the last write.
+func (t *Transcript) openLocked() error {
+	path, err := t.manager.EnsureSession()
+	if err != nil {
+		return err
+	}
+
+	if t.file != nil {
+		cur, err := os.Stat(path)
+		open, openErr := t.file.Stat()
+		if err == nil && openErr == nil && os.SameFile(cur, open) {
+			return nil
+		}
+		t.file.Sync()
+		t.file.Close()
+		t.file = nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open transcript: %w", err)
+	}
+	t.file = f
+	return nil
+}
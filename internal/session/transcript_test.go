@@ -0,0 +1,172 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+// newTranscript returns a transcript writing to a session in a temporary
+// directory.
+func newTranscript(t *testing.T) (*Transcript, *Manager) {
+	t.Helper()
+	manager, err := NewManager(t.TempDir(), 1<<20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTranscript(manager), manager
+}
+
+// messages parses the session file and returns role and body of each
+// message.
+func messages(t *testing.T, manager *Manager) [][2]string {
+	t.Helper()
+	file, err := ParseFile(manager.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][2]string
+	for _, m := range file.Messages {
+		got = append(got, [2]string{m.Role, m.Body})
+	}
+	return got
+}
+
+func checkMessages(t *testing.T, got, want [][2]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got messages %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTranscriptOverlappingRuns(t *testing.T) {
+	tr, manager := newTranscript(t)
+	now := time.Now()
+
+	tr.User("one", now)
+	tr.Delta("run-1", "first ", now)
+	tr.User("two", now)
+	tr.Delta("run-2", "second", now)
+	tr.Delta("run-1", "reply", now)
+	tr.Finish("run-2")
+
+	// run-2 waits for run-1, which is still the last block in the file
+	checkMessages(t, messages(t, manager), [][2]string{
+		{"User", "one"},
+		{"Assistant", "first reply"},
+	})
+
+	tr.Finish("run-1")
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, messages(t, manager), [][2]string{
+		{"User", "one"},
+		{"Assistant", "first reply"},
+		{"User", "two"},
+		{"Assistant", "second"},
+	})
+}
+
+func TestTranscriptFinishEarly(t *testing.T) {
+	tr, manager := newTranscript(t)
+	now := time.Now()
+
+	tr.User("one", now)
+	tr.Delta("run-1", "stalled", now)
+	tr.User("two", now)
+	tr.Delta("run-2", "second", now)
+
+	// Finished early, as when a run stalls: the rest gets written, and
+	// text that still comes goes into a block of its own
+	tr.Finish("run-1")
+	tr.Finish("run-2")
+	tr.Delta("run-1", " after all", now)
+	tr.Finish("run-1")
+	tr.Close()
+
+	checkMessages(t, messages(t, manager), [][2]string{
+		{"User", "one"},
+		{"Assistant", "stalled"},
+		{"User", "two"},
+		{"Assistant", "second"},
+		{"Assistant", " after all"},
+	})
+}
+
+func TestTranscriptClose(t *testing.T) {
+	tr, manager := newTranscript(t)
+	now := time.Now()
+
+	tr.User("one", now)
+	tr.Delta("run-1", "never finishes", now)
+	tr.User("two", now)
+	tr.Delta("run-2", "done", now)
+	tr.Finish("run-2")
+	tr.User("three", now)
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkMessages(t, messages(t, manager), [][2]string{
+		{"User", "one"},
+		{"Assistant", "never finishes"},
+		{"User", "two"},
+		{"Assistant", "done"},
+		{"User", "three"},
+	})
+}
+
+func TestTranscriptReplace(t *testing.T) {
+	tr, manager := newTranscript(t)
+	now := time.Now()
+
+	tr.Delta("run-1", "Hello wrold", now)
+	tr.Replace("run-1", "Hello world", now)
+	tr.Delta("run-1", "!", now)
+	tr.Finish("run-1")
+	tr.Close()
+
+	checkMessages(t, messages(t, manager), [][2]string{
+		{"Assistant", "Hello world!"},
+	})
+}
+
+func TestTranscriptClosesFence(t *testing.T) {
+	tr, manager := newTranscript(t)
+	now := time.Now()
+
+	tr.Delta("run-1", "Here:\n\n```go\nfunc main() {", now)
+	tr.Finish("run-1")
+	tr.User("next", now)
+	tr.Close()
+
+	checkMessages(t, messages(t, manager), [][2]string{
+		{"Assistant", "Here:\n\n```go\nfunc main() {\n```"},
+		{"User", "next"},
+	})
+}
+
+func TestTranscriptUnclosedFencesRoundTrip(t *testing.T) {
+	tr, manager := newTranscript(t)
+	now := time.Now()
+
+	tr.User("Why does this fail?\n\n```\npanic: nil map", now)
+	tr.Delta("run-1", "Try this:\n\n```go\nm := make(map[string]int)", now)
+	tr.Finish("run-1")
+	tr.User("and this?\n\n```go\nx := 1\n```", now)
+	tr.Delta("run-2", "Fine.", now)
+	tr.Finish("run-2")
+	tr.Close()
+
+	checkMessages(t, messages(t, manager), [][2]string{
+		{"User", "Why does this fail?\n\n```\npanic: nil map\n```"},
+		{"Assistant", "Try this:\n\n```go\nm := make(map[string]int)\n```"},
+		{"User", "and this?\n\n```go\nx := 1\n```"},
+		{"Assistant", "Fine."},
+	})
+}
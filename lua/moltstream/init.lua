@@ -11,10 +11,9 @@ local user_buf = nil       -- Buffer for user message history
 local agent_win = nil      -- Window for agent responses
 local user_win = nil       -- Window for user messages
 local config = {}
-local response_in_progress = false
-local pending_response = ""
-local response_start_line = nil
+local responses = {}       -- Open responses by run_id, see begin_response
 local stdout_buffer = ""   -- Buffer for partial stdout lines
+local capabilities = nil   -- What the connected gateway supports (see M.supports)
 
 -- Helper to set buffer lines with undo support
 local function buf_set_lines_undoable(buf, start_line, end_line, lines)
@@ -54,6 +53,11 @@ function M.setup(opts)
   vim.api.nvim_create_user_command("MoltSendCode", M.send_code, {})
   vim.api.nvim_create_user_command("MoltHistory", M.fetch_history, {})
   vim.api.nvim_create_user_command("MoltStatus", M.status, {})
+  vim.api.nvim_create_user_command("MoltCancel", M.cancel, {})
+  vim.api.nvim_create_user_command("MoltQueue", M.queue_list, {})
+  vim.api.nvim_create_user_command("MoltQueueDrop", function(cmd)
+    M.queue_drop(cmd.args ~= "" and cmd.args or nil)
+  end, { nargs = "?" })
 
   -- Setup keymaps
   if config.keymap.open then
@@ -136,7 +140,8 @@ local function rpc_request(method, params)
   local req = vim.fn.json_encode({
     jsonrpc = "2.0",
     method = method,
-    params = params or {},
+    -- json_encode turns an empty table into [], which isn't an object
+    params = params or vim.empty_dict(),
     id = math.random(1, 1000000),
   })
 
@@ -154,10 +159,47 @@ function handle_message(line)
   if msg.method then
     if msg.method == "stream" then
       handle_stream(msg.params)
+    elseif msg.method == "replace" then
+      handle_replace(msg.params)
+    elseif msg.method == "final" or msg.method == "aborted" then
+      finalize_response(msg.params.run_id)
+    elseif msg.method == "run_error" then
+      -- Shown as an error, never written into the response
+      finalize_response(msg.params.run_id)
+      vim.schedule(function()
+        local code = msg.params.code and (" (" .. msg.params.code .. ")") or ""
+        vim.notify("[moltstream] Run failed: " .. (msg.params.message or "unknown") .. code, vim.log.levels.ERROR)
+      end)
+    elseif msg.method == "stalled" then
+      -- An aborted run is finished by the aborted notification that follows
+      vim.schedule(function()
+        local what = msg.params.reason == "first_token" and "No response" or "Response stalled"
+        local action = msg.params.aborted and "; aborted" or "; :MoltCancel to stop waiting"
+        vim.notify(string.format("[moltstream] %s for %ds%s", what, math.floor((msg.params.idle_ms or 0) / 1000), action), vim.log.levels.WARN)
+      end)
+    elseif msg.method == "tool" then
+      handle_tool(msg.params)
+    elseif msg.method == "thinking" then
+      handle_thinking(msg.params)
     elseif msg.method == "connected" then
+      capabilities = msg.params.capabilities
       vim.schedule(function()
         vim.notify("[moltstream] Connected to gateway", vim.log.levels.INFO)
       end)
+    elseif msg.method == "reconnecting" then
+      vim.schedule(function()
+        vim.notify(string.format("[moltstream] Connection lost, reconnecting (attempt %d)...", msg.params.attempt or 0), vim.log.levels.WARN)
+      end)
+    elseif msg.method == "pairing_required" then
+      vim.schedule(function()
+        vim.notify(
+          "[moltstream] Device not paired. Approve it on the gateway:\n"
+            .. "  device: " .. (msg.params.device_id or "?") .. "\n"
+            .. "  key:    " .. (msg.params.fingerprint or "?") .. "\n"
+            .. "Waiting for approval...",
+          vim.log.levels.WARN
+        )
+      end)
     elseif msg.method == "error" then
       vim.schedule(function()
         vim.notify("[moltstream] Error: " .. (msg.params.message or "unknown"), vim.log.levels.ERROR)
@@ -170,16 +212,71 @@ function handle_message(line)
 
   -- Handle responses
   if msg.result then
-    if msg.result.status == "ok" then
-      finalize_response()
+    if msg.result.connected ~= nil then
+      show_status(msg.result)
+    elseif msg.result.features ~= nil then
+      capabilities = msg.result
+    elseif msg.result.entries ~= nil then
+      show_queue(msg.result.entries)
+    elseif msg.result.dropped ~= nil then
+      vim.schedule(function()
+        vim.notify(string.format("[moltstream] Dropped %d queued message(s)", msg.result.dropped), vim.log.levels.INFO)
+      end)
+    elseif msg.result.status == "queued" then
+      vim.schedule(function()
+        vim.notify(string.format("[moltstream] Gateway unreachable; message queued (%d waiting)", msg.result.queued or 1), vim.log.levels.WARN)
+      end)
     end
   elseif msg.error then
+    if msg.error.code == -32006 or msg.error.code == -32007 then
+      return -- Already reported by the run_error or stalled notification
+    end
     vim.schedule(function()
       vim.notify("[moltstream] " .. (msg.error.message or "unknown error"), vim.log.levels.ERROR)
     end)
   end
 end
 
+-- Show the result of a status request
+function show_status(result)
+  local lines = {
+    "[moltstream] " .. (result.connected and "Connected" or "Disconnected") .. " to " .. (result.gateway or "?"),
+    "  session: " .. (result.session_id ~= "" and result.session_id or "-"),
+  }
+  if result.pairing then
+    table.insert(lines, "  waiting for device approval")
+  end
+  if result.queued then
+    table.insert(lines, string.format("  %d message(s) queued", result.queued))
+  end
+  if result.protocol then
+    table.insert(lines, string.format("  gateway %s, protocol %d", result.server_version or "?", result.protocol))
+  end
+  if result.heartbeat_age_ms then
+    table.insert(lines, string.format("  last heartbeat: %.1fs ago, latency %dms",
+      result.heartbeat_age_ms / 1000, result.latency_ms or 0))
+  end
+  vim.schedule(function()
+    vim.notify(table.concat(lines, "\n"), vim.log.levels.INFO)
+  end)
+end
+
+-- Show the messages waiting in the outbox
+function show_queue(entries)
+  local lines = { string.format("[moltstream] %d message(s) queued", #entries) }
+  for _, e in ipairs(entries) do
+    local first = vim.split(e.content or "", "\n", { plain = true })[1]
+    if #first > 60 then
+      first = first:sub(1, 57) .. "..."
+    end
+    local at = e.queued_at and os.date("%H:%M", math.floor(e.queued_at / 1000)) or "?"
+    table.insert(lines, string.format("  %s [%s] %s", e.run_id, at, first))
+  end
+  vim.schedule(function()
+    vim.notify(table.concat(lines, "\n"), vim.log.levels.INFO)
+  end)
+end
+
 -- Create or get the agent buffer
 local function ensure_agent_buf()
   if agent_buf and vim.api.nvim_buf_is_valid(agent_buf) then
@@ -226,46 +323,140 @@ local function ensure_user_buf()
   return user_buf
 end
 
+-- Return the open response of a run, starting a block for it at the end
+-- of the agent buffer if there is none. Several runs may stream at once;
+-- each response knows the lines of its body (start, count), which
+-- render_response keeps up to date as blocks above it grow.
+local function begin_response(buf, run_id)
+  local r = responses[run_id or ""]
+  if r then
+    return r
+  end
+
+  -- Insert response header
+  local timestamp = os.date("%H:%M")
+  local line_count = vim.api.nvim_buf_line_count(buf)
+  local header = {
+    "---",
+    "",
+    "## [" .. timestamp .. "]",
+    "",
+  }
+  vim.api.nvim_buf_set_lines(buf, line_count, line_count, false, header)
+
+  r = {
+    start = vim.api.nvim_buf_line_count(buf),
+    count = 0,
+    text = "",
+    activity = {},        -- Tool calls and reasoning, shown above the text
+    activity_index = {},  -- Key (tool call ID, "thinking") -> index in activity
+    thinking_chars = 0,   -- Length of the reasoning text
+  }
+  responses[run_id or ""] = r
+  return r
+end
+
+-- Replace the body of response r with lines, moving the blocks below it
+local function set_response_lines(buf, r, lines)
+  vim.api.nvim_buf_set_lines(buf, r.start, r.start + r.count, false, lines)
+  local shift = #lines - r.count
+  r.count = #lines
+  if shift ~= 0 then
+    for _, other in pairs(responses) do
+      if other ~= r and other.start > r.start then
+        other.start = other.start + shift
+      end
+    end
+  end
+end
+
+-- Redraw a response: the activity log, then the answer so far
+local function render_response(buf, r)
+  local lines = {}
+  for _, entry in ipairs(r.activity) do
+    table.insert(lines, "> " .. entry.text)
+  end
+  if #r.activity > 0 then
+    table.insert(lines, "")
+  end
+  vim.list_extend(lines, vim.split(r.text, "\n", { plain = true }))
+  set_response_lines(buf, r, lines)
+
+  -- Auto-scroll agent window
+  if config.auto_scroll and agent_win and vim.api.nvim_win_is_valid(agent_win) then
+    local new_line_count = vim.api.nvim_buf_line_count(buf)
+    vim.api.nvim_win_set_cursor(agent_win, { new_line_count, 0 })
+  end
+end
+
+-- Add or update an activity log entry of response r by key
+local function set_activity(r, key, text)
+  local i = r.activity_index[key]
+  if i then
+    r.activity[i].text = text
+  else
+    table.insert(r.activity, { text = text })
+    r.activity_index[key] = #r.activity
+  end
+end
+
 -- Handle streaming response
 function handle_stream(params)
   vim.schedule(function()
     local buf = ensure_agent_buf()
-    
-    -- Start new response block if needed
-    if not response_in_progress then
-      response_in_progress = true
-      pending_response = ""
-      
-      -- Insert response header
-      local timestamp = os.date("%H:%M")
-      local line_count = vim.api.nvim_buf_line_count(buf)
-      local header = {
-        "---",
-        "",
-        "## [" .. timestamp .. "]",
-        "",
-      }
-      vim.api.nvim_buf_set_lines(buf, line_count, line_count, false, header)
-      response_start_line = vim.api.nvim_buf_line_count(buf)
-    end
+    local r = begin_response(buf, params.run_id)
 
     -- Append delta
     if params.delta and params.delta ~= "" then
-      pending_response = pending_response .. params.delta
-      
-      -- Update buffer with current response
-      local lines = vim.split(pending_response, "\n", { plain = true })
-      
-      if response_start_line then
-        vim.api.nvim_buf_set_lines(buf, response_start_line, -1, false, lines)
-      end
-      
-      -- Auto-scroll agent window
-      if config.auto_scroll and agent_win and vim.api.nvim_win_is_valid(agent_win) then
-        local new_line_count = vim.api.nvim_buf_line_count(buf)
-        vim.api.nvim_win_set_cursor(agent_win, { new_line_count, 0 })
+      r.text = r.text .. params.delta
+      render_response(buf, r)
+    end
+  end)
+end
+
+-- The gateway revised text it already sent; rewrite the whole response
+function handle_replace(params)
+  vim.schedule(function()
+    local buf = ensure_agent_buf()
+    local r = begin_response(buf, params.run_id)
+
+    r.text = params.text or ""
+    render_response(buf, r)
+  end)
+end
+
+-- Log tool calls above the response
+function handle_tool(params)
+  vim.schedule(function()
+    local buf = ensure_agent_buf()
+    local r = begin_response(buf, params.run_id)
+
+    local name = params.name or "tool"
+    local text
+    if params.phase == "start" then
+      local args = params.args and vim.fn.json_encode(params.args) or ""
+      if #args > 80 then
+        args = args:sub(1, 77) .. "..."
       end
+      text = string.format("`%s` %s _running_", name, args)
+    else
+      local took = params.duration_ms and string.format(", %.1fs", params.duration_ms / 1000) or ""
+      text = string.format("`%s` _%s%s_", name, params.status or "done", took)
     end
+    set_activity(r, "tool:" .. (params.call_id or name), text)
+    render_response(buf, r)
+  end)
+end
+
+-- Log that the agent is reasoning; the text itself isn't part of the answer
+function handle_thinking(params)
+  vim.schedule(function()
+    local buf = ensure_agent_buf()
+    local r = begin_response(buf, params.run_id)
+
+    r.thinking_chars = r.thinking_chars + #(params.delta or "")
+    set_activity(r, "thinking", string.format("_thinking (%d chars)_", r.thinking_chars))
+    render_response(buf, r)
   end)
 end
 
@@ -283,7 +474,8 @@ function handle_history(params)
       for _, msg in ipairs(params.messages) do
         table.insert(lines, "---")
         table.insert(lines, "")
-        table.insert(lines, "## " .. (msg.role or "user") .. " [" .. (msg.timestamp or "") .. "]")
+        local timestamp = msg.timestamp and os.date("%Y-%m-%d %H:%M", math.floor(msg.timestamp / 1000)) or ""
+        table.insert(lines, "## " .. (msg.role or "user") .. " [" .. timestamp .. "]")
         table.insert(lines, "")
         if msg.content then
           for _, line in ipairs(vim.split(msg.content, "\n", { plain = true })) do
@@ -300,16 +492,21 @@ function handle_history(params)
   end)
 end
 
--- Finalize the response
-function finalize_response()
-  response_in_progress = false
-  pending_response = ""
-  response_start_line = nil
-  
+-- Finalize the response of a run
+function finalize_response(run_id)
   vim.schedule(function()
+    local r = responses[run_id or ""]
+    if not r then
+      return
+    end
     if agent_buf and vim.api.nvim_buf_is_valid(agent_buf) then
-      vim.api.nvim_buf_set_lines(agent_buf, -1, -1, false, { "", "" })
+      -- Close the block with blank lines, after its body rather than at
+      -- the end of the buffer, where another response may be streaming
+      local lines = vim.api.nvim_buf_get_lines(agent_buf, r.start, r.start + r.count, false)
+      vim.list_extend(lines, { "", "" })
+      set_response_lines(agent_buf, r, lines)
     end
+    responses[run_id or ""] = nil
   end)
 end
 
@@ -379,9 +576,7 @@ end
 -- Clear/reset everything (use when state gets broken)
 function M.clear()
   -- Reset all state
-  response_in_progress = false
-  pending_response = ""
-  response_start_line = nil
+  responses = {}
   stdout_buffer = ""
   
   -- Reset agent buffer
@@ -491,11 +686,6 @@ end
 
 -- Send any message string
 function M.send_message(message)
-  if response_in_progress then
-    vim.notify("[moltstream] Response in progress", vim.log.levels.WARN)
-    return
-  end
-
   if not start_bridge() then
     return
   end
@@ -534,13 +724,26 @@ function M.send_message(message)
   rpc_request("send", { content = message })
 end
 
+-- Whether the connected gateway supports a bridge feature ("history",
+-- "cancel", ...). Unknown until connected, when everything is allowed.
+function M.supports(feature)
+  if not capabilities or not capabilities.features then
+    return true
+  end
+  return capabilities.features[feature] ~= false
+end
+
 -- Fetch message history from server
 function M.fetch_history()
   if not start_bridge() then
     return
   end
+  if not M.supports("history") then
+    vim.notify("[moltstream] History is not supported by this gateway", vim.log.levels.WARN)
+    return
+  end
   
-  rpc_request("history", {})
+  rpc_request("history")
   vim.notify("[moltstream] Fetching history...", vim.log.levels.INFO)
 end
 
@@ -550,7 +753,34 @@ function M.status()
     return
   end
   
-  rpc_request("status", {})
+  rpc_request("status")
+end
+
+-- Cancel the response that is currently streaming
+function M.cancel()
+  if not job_id or not M.supports("cancel") then
+    return
+  end
+
+  rpc_request("cancel")
+end
+
+-- List messages queued while the gateway was unreachable
+function M.queue_list()
+  if not start_bridge() then
+    return
+  end
+
+  rpc_request("queue_list")
+end
+
+-- Drop a queued message by run id, or all of them
+function M.queue_drop(run_id)
+  if not start_bridge() then
+    return
+  end
+
+  rpc_request("queue_drop", run_id and { run_id = run_id })
 end
 
 -- Stop the bridge
@@ -558,6 +788,7 @@ function M.stop()
   if job_id then
     vim.fn.jobstop(job_id)
     job_id = nil
+    capabilities = nil
   end
 end
 
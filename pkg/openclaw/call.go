@@ -0,0 +1,135 @@
+package openclaw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultRequestTimeout bounds a Call whose context has no deadline.
+const DefaultRequestTimeout = 15 * time.Second
+
+var (
+	// ErrNotConnected is returned for requests made before the connect
+	// handshake completes.
+	ErrNotConnected = errors.New("not connected")
+
+	// ErrConnectionLost is returned for requests still waiting for their
+	// response when the connection drops.
+	ErrConnectionLost = errors.New("connection lost")
+)
+
+// Error makes FrameError usable as the error returned by Call.
+func (e *FrameError) Error() string {
+	msg := e.Message
+	if e.Method != "" {
+		msg = e.Method + ": " + msg
+	}
+	if code := e.CodeString(); code != "" {
+		return fmt.Sprintf("%s (%s)", msg, code)
+	}
+	return msg
+}
+
+// CodeString returns the error code as a string, whether the gateway sent
+// it as a number or a string.
+func (e *FrameError) CodeString() string {
+	return codeString(e.Code)
+}
+
+func codeString(v interface{}) string {
+	switch code := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return code
+	case float64:
+		return fmt.Sprintf("%g", code)
+	default:
+		return fmt.Sprint(code)
+	}
+}
+
+// Call sends a req frame and waits for the res frame with the same ID.
+// It returns the response payload, or a *FrameError if the gateway
+// rejected the request. Without a deadline on ctx, the wait is bounded by
+// DefaultRequestTimeout.
+func (c *Client) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+	}
+
+	c.mu.Lock()
+	if !c.connected || c.conn == nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%s: %w", method, ErrNotConnected)
+	}
+
+	c.reqID++
+	id := fmt.Sprintf("%s-%d", method, c.reqID)
+	ch := make(chan *GatewayFrame, 1)
+	c.pending[id] = ch
+
+	err := c.conn.WriteJSON(map[string]interface{}{
+		"type":   "req",
+		"id":     id,
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("send %s: %w", method, err)
+	}
+	c.mu.Unlock()
+
+	select {
+	case frame := <-ch:
+		if frame == nil {
+			return nil, fmt.Errorf("%s: %w", method, ErrConnectionLost)
+		}
+		if !frame.Ok {
+			frameErr := frame.Error
+			if frameErr == nil {
+				frameErr = &FrameError{Message: "request failed"}
+			}
+			frameErr.Method = method
+			return nil, frameErr
+		}
+		if len(frame.Payload) > 0 {
+			return frame.Payload, nil
+		}
+		return frame.Result, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// deliver hands a res frame to the Call waiting for it, reporting whether
+// there was one.
+func (c *Client) deliver(frame *GatewayFrame) bool {
+	c.mu.Lock()
+	ch, ok := c.pending[frame.ID]
+	delete(c.pending, frame.ID)
+	c.mu.Unlock()
+	if ok {
+		ch <- frame
+	}
+	return ok
+}
+
+// failPendingLocked fails every outstanding Call with ErrConnectionLost.
+// c.mu must be held.
+func (c *Client) failPendingLocked() {
+	for id, ch := range c.pending {
+		delete(c.pending, id)
+		ch <- nil
+	}
+}
@@ -0,0 +1,855 @@
+package openclaw
+
+import (
+	"context"
+	"crypto/ed25519"
+	crand "crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Default reconnect backoff bounds, used without WithBackoff.
+const (
+	DefaultInitialDelay = 500 * time.Millisecond
+	DefaultMaxDelay     = 30 * time.Second
+)
+
+// ErrClosed is returned by Connect after Close.
+var ErrClosed = errors.New("client closed")
+
+// ErrNoActiveRun is returned by Abort when the run is unknown or finished.
+var ErrNoActiveRun = errors.New("no active run")
+
+// Client is a connection to an OpenClaw gateway. Everything the gateway
+// pushes (run output, connection state) arrives on Events, which must be
+// drained.
+type Client struct {
+	url          string
+	token        string
+	identityPath string
+	conn         *websocket.Conn
+	mu           sync.Mutex
+	connected    bool
+	closed       bool // Close was called; Events is closed
+	connectNonce string
+	connectID    string // ID of the outstanding connect req
+	events       chan Event
+	eventBuffer  int
+	deviceID     string
+	privateKey   ed25519.PrivateKey
+	reqID        int
+	runs         map[string]*run               // In-flight runs keyed by runId
+	chatMu       sync.Mutex                    // Held while applying chat events, before mu
+	reorder      time.Duration                 // How long to hold chat events that skip a seq
+	pending      map[string]chan *GatewayFrame // Requests awaiting a res frame, keyed by frame ID
+	life         context.Context               // Canceled by Close to stop the reconnect loop
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup // Goroutines Close waits for
+	failures     int            // Consecutive failed connection attempts
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	connectRetry bool // Retry a failed first dial, see WithConnectRetry
+	pairing      bool // Connect was rejected until the device is approved
+	pairingRetry time.Duration
+	tlsConfig    *tls.Config
+	proxy        func(*http.Request) (*url.URL, error)
+	netDial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	version      string
+	hello        *Hello
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	lastBeat     time.Time
+	rtt          time.Duration
+	log          *slog.Logger
+}
+
+// run is the client-side state of one in-flight chat.send.
+type run struct {
+	sessionKey string
+	message    string    // What was sent, repeated to resume the run
+	accepted   bool      // chat.send succeeded; the gateway knows the run
+	content    string    // Accumulated text, used to compute deltas
+	thinking   string    // Accumulated reasoning text
+	started    time.Time // When chat.send was written
+	tools      map[string]*toolCall
+	seq        int                // Seq of the last chat event applied
+	held       map[int]*chatEvent // Chat events waiting for a missing seq
+	flushing   bool               // A reorder timer is pending
+}
+
+// GatewayFrame is a message on the gateway connection: a request ("req"),
+// its response ("res") or a pushed event ("event"). Which fields are set
+// depends on Type.
+type GatewayFrame struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *FrameError     `json:"error,omitempty"`
+	Ok      bool            `json:"ok,omitempty"`
+}
+
+// FrameError is the error of a failed request, as the gateway sent it.
+type FrameError struct {
+	Code    interface{} `json:"code"` // Can be int or string
+	Message string      `json:"message"`
+	Method  string      `json:"-"` // Set by Call to the method of the failed request
+}
+
+// ConnectChallenge is the payload of the "connect.challenge" event the
+// gateway sends on a new connection. The connect request signs Nonce.
+type ConnectChallenge struct {
+	Nonce string `json:"nonce"`
+	Ts    int64  `json:"ts"`
+}
+
+// chatEvent is the payload of "chat" events, which carry the accumulated
+// message of a run and its state.
+type chatEvent struct {
+	RunID   string `json:"runId"`
+	Seq     int    `json:"seq"`
+	State   string `json:"state"`
+	Message struct {
+		Content    []ContentPart `json:"content,omitempty"`
+		StopReason string        `json:"stopReason,omitempty"`
+		Usage      *Usage        `json:"usage,omitempty"`
+	} `json:"message,omitempty"`
+	StopReason   string      `json:"stopReason,omitempty"`
+	Usage        *Usage      `json:"usage,omitempty"`
+	ErrorCode    interface{} `json:"errorCode,omitempty"` // String or number
+	ErrorMessage string      `json:"errorMessage,omitempty"`
+}
+
+// New creates a client for the gateway at url (ws:// or wss://). It signs
+// in with the device identity at DefaultIdentityPath unless
+// WithIdentityFile says otherwise, and fails with ErrNoIdentity if there
+// is none.
+func New(url string, opts ...Option) (*Client, error) {
+	c := &Client{
+		url:          url,
+		runs:         make(map[string]*run),
+		pending:      make(map[string]chan *GatewayFrame),
+		eventBuffer:  DefaultEventBuffer,
+		initialDelay: DefaultInitialDelay,
+		maxDelay:     DefaultMaxDelay,
+		pairingRetry: DefaultPairingRetry,
+		pingInterval: DefaultPingInterval,
+		pongTimeout:  DefaultPongTimeout,
+		reorder:      DefaultReorderWindow,
+		proxy:        environmentProxy,
+		version:      "dev",
+		log:          slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.identityPath == "" {
+		path, err := DefaultIdentityPath()
+		if err != nil {
+			return nil, err
+		}
+		c.identityPath = path
+	}
+	identity, key, err := LoadIdentity(c.identityPath)
+	if err != nil {
+		return nil, err
+	}
+	c.deviceID = identity.DeviceID
+	c.privateKey = key
+
+	c.events = make(chan Event, c.eventBuffer)
+	return c, nil
+}
+
+// Events returns the channel carrying run output and connection state.
+// It is closed by Close. While it is full the client stops reading from
+// the gateway, so keep draining it.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// emit queues ev on Events, giving up if the client is disconnected
+// meanwhile.
+func (c *Client) emit(ev Event) {
+	c.mu.Lock()
+	life := c.life
+	c.mu.Unlock()
+	if life == nil {
+		return
+	}
+	select {
+	case c.events <- ev:
+	case <-life.Done():
+	}
+}
+
+// Connect dials the gateway; ctx bounds the dial only. Once connected,
+// dropped connections are redialed automatically until Close is called.
+// If the dial fails the client is left disconnected, or with
+// WithConnectRetry keeps redialing in the background.
+func (c *Client) Connect(ctx context.Context) error {
+	life, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		cancel()
+		return ErrClosed
+	}
+	if c.life != nil {
+		c.mu.Unlock()
+		cancel()
+		return errors.New("already connected")
+	}
+	c.life = life
+	c.cancel = cancel
+	c.failures = 0
+	c.mu.Unlock()
+
+	err := c.dial(ctx, life)
+	if err == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	retry := c.connectRetry && c.life == life && ctx.Err() == nil
+	if retry {
+		c.wg.Add(1) // Under c.mu, as in dial
+	} else if c.life == life {
+		c.life, c.cancel = nil, nil
+	}
+	c.mu.Unlock()
+
+	if !retry {
+		cancel()
+		return err
+	}
+	c.log.Warn("connect failed, retrying", "err", err)
+	go func() {
+		defer c.wg.Done()
+		c.emit(ConnectionError{Err: err})
+		c.reconnectLoop(life)
+	}()
+	return err
+}
+
+// dial opens a connection for the client lifetime life. ctx bounds the
+// dial itself.
+func (c *Client) dial(ctx context.Context, life context.Context) error {
+	c.mu.Lock()
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:  c.tlsConfig,
+		Proxy:            c.proxy,
+		NetDialContext:   c.netDial,
+	}
+	c.mu.Unlock()
+
+	conn, _, err := dialer.DialContext(ctx, c.url, http.Header{})
+	if err != nil {
+		return fmt.Errorf("websocket dial: %w", err)
+	}
+
+	c.mu.Lock()
+	if c.life != life {
+		// Closed (or reconnected manually) while dialing
+		c.mu.Unlock()
+		conn.Close()
+		return ErrClosed
+	}
+	c.conn = conn
+	c.connected = false
+	c.connectNonce = ""
+	c.connectID = ""
+	c.hello = nil
+	c.wg.Add(1) // readLoop; under c.mu so Close can't be waiting yet
+	c.mu.Unlock()
+
+	stop := make(chan struct{})
+	c.startHeartbeat(conn, stop)
+
+	// Don't send connect yet - wait for challenge
+	go func() {
+		defer c.wg.Done()
+		c.readLoop(conn, life, stop)
+	}()
+
+	return nil
+}
+
+func (c *Client) readLoop(conn *websocket.Conn, life context.Context, stop chan struct{}) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			close(stop)
+			conn.Close()
+
+			c.mu.Lock()
+			current := c.conn == conn && c.life == life
+			if current {
+				c.connected = false
+				c.failPendingLocked()
+			}
+			pairing := c.pairing
+			c.mu.Unlock()
+
+			// Closed on purpose or superseded by a newer connection
+			if !current {
+				return
+			}
+
+			if pairing {
+				c.pairingLoop(life)
+				return
+			}
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = fmt.Errorf("no heartbeat for %s: %w", c.pongTimeout, err)
+			}
+			c.emit(ConnectionError{Err: fmt.Errorf("read: %w", err)})
+			c.reconnectLoop(life)
+			return
+		}
+
+		var frame GatewayFrame
+		if err := json.Unmarshal(message, &frame); err != nil {
+			c.log.Warn("parse frame", "err", err, "raw", string(message))
+			continue
+		}
+		c.alive(conn, frame.Type == "event" && frame.Event == "tick")
+
+		c.handleFrame(&frame)
+	}
+}
+
+// reconnectLoop redials with capped exponential backoff and jitter until
+// a dial succeeds or the client is closed.
+func (c *Client) reconnectLoop(life context.Context) {
+	for {
+		c.mu.Lock()
+		c.failures++
+		attempt := c.failures
+		c.mu.Unlock()
+
+		delay := c.backoffDelay(attempt)
+		c.emit(Reconnecting{Attempt: attempt, Delay: delay})
+
+		select {
+		case <-time.After(delay):
+		case <-life.Done():
+			return
+		}
+
+		err := c.dial(life, life)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+		c.log.Warn("reconnect failed", "attempt", attempt, "err", err)
+	}
+}
+
+// backoffDelay doubles the initial delay per attempt up to maxDelay, then
+// picks a random point in the upper half so clients don't redial in lockstep.
+func (c *Client) backoffDelay(attempt int) time.Duration {
+	delay := c.initialDelay
+	for i := 1; i < attempt && delay < c.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func (c *Client) handleFrame(frame *GatewayFrame) {
+	switch frame.Type {
+	case "event":
+		c.handleEvent(frame)
+	case "res":
+		c.log.Debug("response", "id", frame.ID, "ok", frame.Ok)
+
+		c.mu.Lock()
+		isConnect := frame.ID != "" && frame.ID == c.connectID
+		if isConnect {
+			c.connectID = ""
+		}
+		c.mu.Unlock()
+
+		switch {
+		case isConnect:
+			c.handleConnectResponse(frame)
+		case c.deliver(frame):
+		default:
+			// The Call gave up (timeout or context) before the answer came
+			c.log.Warn("unmatched response", "id", frame.ID, "ok", frame.Ok)
+		}
+	}
+}
+
+// handleConnectResponse finishes the handshake started by sendConnect.
+func (c *Client) handleConnectResponse(frame *GatewayFrame) {
+	if !frame.Ok {
+		if isPairingRequired(frame.Error) {
+			c.handlePairingRequired()
+			return
+		}
+		err := frame.Error
+		if err == nil {
+			err = &FrameError{Message: "connect rejected"}
+		}
+		c.log.Error("connect rejected", "code", err.CodeString(), "message", err.Message)
+		c.emit(ConnectionError{Err: fmt.Errorf("connect: %w", err)})
+		return
+	}
+
+	hello, err := parseHello(frame.Payload)
+	if err != nil {
+		c.log.Error("unusable hello", "err", err)
+		c.emit(ConnectionError{Err: err})
+		// Retrying won't change the protocol; wait for an explicit reconnect
+		go c.disconnect()
+		return
+	}
+	c.log.Info("connected", "protocol", hello.Protocol, "server", hello.Server.Version)
+
+	c.mu.Lock()
+	c.hello = hello
+	wasConnected := c.connected
+	c.connected = true
+	c.failures = 0
+	c.pairing = false
+	resume := !wasConnected && c.startResumeLocked()
+	c.mu.Unlock()
+
+	if !wasConnected {
+		c.emit(Connected{Hello: hello})
+	}
+	if resume {
+		c.log.Info("resuming runs after reconnect")
+	}
+}
+
+func (c *Client) handleEvent(frame *GatewayFrame) {
+	c.log.Debug("event", "event", frame.Event)
+	switch frame.Event {
+	case "connect.challenge":
+		c.handleChallenge(frame.Payload)
+	case "chat":
+		c.handleChatEvent(frame.Payload)
+	case "agent":
+		c.handleAgentEvent(frame.Payload)
+	}
+}
+
+func (c *Client) handleChallenge(payload json.RawMessage) {
+	var challenge ConnectChallenge
+	if err := json.Unmarshal(payload, &challenge); err != nil {
+		c.log.Warn("parse challenge", "err", err)
+		return
+	}
+
+	c.log.Debug("received challenge, sending auth connect")
+	c.mu.Lock()
+	c.connectNonce = challenge.Nonce
+	c.mu.Unlock()
+	c.sendConnect()
+}
+
+func (c *Client) sendConnect() {
+	c.mu.Lock()
+	nonce := c.connectNonce
+	version := c.version
+	c.reqID++
+	connectID := fmt.Sprintf("connect-%d", c.reqID)
+	c.connectID = connectID
+	c.mu.Unlock()
+
+	signedAt := time.Now().UnixMilli()
+	// Format: v2|deviceId|clientId|clientMode|role|scopes|signedAtMs|token|nonce
+	scopes := "operator.admin"
+	token := c.token
+	authPayload := fmt.Sprintf("v2|%s|cli|cli|operator|%s|%d|%s|%s",
+		c.deviceID, scopes, signedAt, token, nonce)
+
+	signature := ed25519.Sign(c.privateKey, []byte(authPayload))
+	sigB64 := base64.RawURLEncoding.EncodeToString(signature)
+
+	pubKeyRaw := c.privateKey.Public().(ed25519.PublicKey)
+	pubKeyB64 := base64.RawURLEncoding.EncodeToString(pubKeyRaw)
+
+	connectFrame := map[string]interface{}{
+		"type":   "req",
+		"id":     connectID,
+		"method": "connect",
+		"params": map[string]interface{}{
+			"minProtocol": Protocol,
+			"maxProtocol": Protocol,
+			"client": map[string]interface{}{
+				"id":       "cli",
+				"version":  version,
+				"platform": runtime.GOOS,
+				"mode":     "cli",
+			},
+			"role":   "operator",
+			"scopes": []string{"operator.admin"},
+			"auth": map[string]interface{}{
+				"token": c.token,
+			},
+			"device": map[string]interface{}{
+				"id":        c.deviceID,
+				"publicKey": pubKeyB64,
+				"signature": sigB64,
+				"signedAt":  signedAt,
+				"nonce":     nonce,
+			},
+		},
+	}
+
+	c.log.Info("sending connect", "device", c.deviceID)
+	c.mu.Lock()
+	err := ErrClosed
+	if c.conn != nil {
+		err = c.conn.WriteJSON(connectFrame)
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		c.log.Error("send connect", "err", err)
+	}
+}
+
+func (c *Client) handleChatEvent(payload json.RawMessage) {
+	var event chatEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		c.log.Warn("parse chat event", "err", err)
+		return
+	}
+
+	c.log.Debug("chat event", "run", event.RunID, "seq", event.Seq, "state", event.State)
+
+	// Serializes applying events with the reorder timers
+	c.chatMu.Lock()
+	defer c.chatMu.Unlock()
+
+	// Filter: only process events for runs we started
+	c.mu.Lock()
+	r, ok := c.runs[event.RunID]
+	var ready []*chatEvent
+	if ok {
+		ready = c.sequenceLocked(r, &event)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		// Ignore events from other sessions/requests
+		c.log.Debug("ignoring chat event for unknown run", "run", event.RunID)
+		return
+	}
+	for _, ev := range ready {
+		c.applyChatEvent(r, ev)
+	}
+}
+
+// applyChatEvent emits what a chat event adds to its run. Events must be
+// applied in seq order; c.chatMu must be held.
+func (c *Client) applyChatEvent(r *run, event *chatEvent) {
+	var fullText string
+	var hasText bool
+	for _, part := range event.Message.Content {
+		if part.Type == "text" {
+			fullText += part.Text
+			hasText = true
+		}
+	}
+
+	done := event.State == "final" || event.State == "error" || event.State == "aborted"
+
+	c.mu.Lock()
+	lastContent := r.content
+	if !hasText {
+		// Events without a message (a bare final, or only tool activity)
+		// leave the text as it is
+		fullText = lastContent
+	}
+	if event.State != "error" {
+		r.content = fullText
+	}
+	activity := r.activity(event.RunID, event.Message.Content)
+	if done {
+		delete(c.runs, event.RunID)
+	}
+	c.mu.Unlock()
+
+	if done {
+		c.log.Info("run finished", "run", event.RunID, "state", event.State, "elapsed", time.Since(r.started).Round(time.Millisecond))
+	}
+
+	for _, ev := range activity {
+		c.emit(ev)
+	}
+
+	// The gateway sends the accumulated text; usually it only grows, and
+	// the new suffix is the delta. Anything else rewrote delivered text.
+	if event.State != "error" && fullText != lastContent {
+		if strings.HasPrefix(fullText, lastContent) {
+			c.emit(Delta{RunID: event.RunID, Delta: fullText[len(lastContent):], Text: fullText})
+		} else {
+			c.log.Debug("chat text rewritten", "run", event.RunID, "seq", event.Seq, "was", len(lastContent), "now", len(fullText))
+			c.emit(Replace{RunID: event.RunID, Text: fullText})
+		}
+	}
+
+	switch event.State {
+	case "final":
+		final := Final{
+			RunID:      event.RunID,
+			Text:       fullText,
+			StopReason: event.Message.StopReason,
+			Usage:      event.Message.Usage,
+		}
+		// Older gateways report these next to the message
+		if final.StopReason == "" {
+			final.StopReason = event.StopReason
+		}
+		if final.Usage == nil {
+			final.Usage = event.Usage
+		}
+		c.emit(final)
+	case "error":
+		c.emit(Error{
+			RunID:   event.RunID,
+			Code:    codeString(event.ErrorCode),
+			Message: event.ErrorMessage,
+			Text:    lastContent,
+		})
+	case "aborted":
+		c.emit(Aborted{RunID: event.RunID, Text: fullText})
+	}
+}
+
+// agentEvent is the payload of "agent" events, which stream what the agent
+// does during a run. Only the "tool" and "thinking" streams are surfaced.
+type agentEvent struct {
+	RunID  string `json:"runId"`
+	Stream string `json:"stream"`
+	Data   struct {
+		// "tool"
+		Phase      string          `json:"phase"`
+		Name       string          `json:"name"`
+		ToolCallID string          `json:"toolCallId"`
+		Args       json.RawMessage `json:"args,omitempty"`
+		Result     json.RawMessage `json:"result,omitempty"`
+		IsError    bool            `json:"isError,omitempty"`
+
+		// "thinking"
+		Text  string `json:"text,omitempty"`
+		Delta string `json:"delta,omitempty"`
+	} `json:"data"`
+}
+
+func (c *Client) handleAgentEvent(payload json.RawMessage) {
+	var event agentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		c.log.Warn("parse agent event", "err", err)
+		return
+	}
+
+	c.mu.Lock()
+	r, ok := c.runs[event.RunID]
+	var ev Event
+	var fresh bool
+	if ok {
+		switch {
+		case event.Stream == "thinking":
+			ev, fresh = r.think(event.RunID, event.Data.Text, event.Data.Delta)
+		case event.Stream == "tool" && event.Data.Phase == "start":
+			ev, fresh = r.toolStart(event.RunID, event.Data.ToolCallID, event.Data.Name, event.Data.Args)
+		case event.Stream == "tool" && event.Data.Phase == "result":
+			ev, fresh = r.toolResult(event.RunID, event.Data.ToolCallID, event.Data.Name, event.Data.Result, event.Data.IsError)
+		}
+	}
+	c.mu.Unlock()
+
+	if fresh {
+		c.emit(ev)
+	}
+}
+
+// NewRunID returns a fresh idempotency key for Send. The gateway uses it
+// as the runId of the resulting run. Keys are random, so keys made at the
+// same time, in one process or several, don't collide.
+func NewRunID() string {
+	var b [16]byte
+	crand.Read(b[:]) // Never fails
+	return "molt-" + hex.EncodeToString(b[:])
+}
+
+// Send starts run runID (see NewRunID) in the given session and waits for
+// the gateway to accept it; the content then arrives on Events,
+// possibly before Send returns. Several runs may stream at once.
+func (c *Client) Send(ctx context.Context, runID, sessionKey, content string) error {
+	c.mu.Lock()
+	if !c.connected || c.conn == nil {
+		c.mu.Unlock()
+		return fmt.Errorf("chat.send: %w", ErrNotConnected)
+	}
+	// Gateway uses idempotencyKey as runId, so track it now
+	r := &run{sessionKey: sessionKey, message: content, started: time.Now()}
+	c.runs[runID] = r
+	c.mu.Unlock()
+
+	c.log.Info("sending chat.send", "run", runID, "session", sessionKey)
+	status, err := c.sendRun(ctx, runID, r)
+	if err != nil {
+		c.mu.Lock()
+		delete(c.runs, runID)
+		c.mu.Unlock()
+		return err
+	}
+
+	c.mu.Lock()
+	r.accepted = true
+	life := c.life
+	settle := life != nil && runEnded(status)
+	if settle {
+		c.wg.Add(1)
+	}
+	c.mu.Unlock()
+
+	if settle {
+		// A repeated runId whose run already ended; its events are gone
+		go func() {
+			defer c.wg.Done()
+			c.settleRun(life, runID, status)
+		}()
+	}
+	return nil
+}
+
+// sendRun writes the chat.send of run r and returns the status the gateway
+// answers with.
+func (c *Client) sendRun(ctx context.Context, runID string, r *run) (string, error) {
+	raw, err := c.Call(ctx, "chat.send", map[string]interface{}{
+		"sessionKey":     r.sessionKey,
+		"message":        r.message,
+		"idempotencyKey": runID,
+	})
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		Status string `json:"status"`
+	}
+	json.Unmarshal(raw, &res)
+	return res.Status, nil
+}
+
+// Abort asks the gateway to stop a run and stops tracking it locally, so
+// late events for the run are ignored. It returns the content received so
+// far without waiting for the gateway; a rejected abort is only logged.
+func (c *Client) Abort(runID string) (partial string, err error) {
+	c.mu.Lock()
+	r, ok := c.runs[runID]
+	if !ok {
+		c.mu.Unlock()
+		return "", ErrNoActiveRun
+	}
+	partial = r.content
+	delete(c.runs, runID)
+	if !c.connected {
+		// Nothing to tell the gateway; the run is gone with the connection
+		c.mu.Unlock()
+		return partial, nil
+	}
+	life := c.life
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	c.log.Info("sending chat.abort", "run", runID)
+	go func() {
+		defer c.wg.Done()
+		_, err := c.Call(life, "chat.abort", map[string]interface{}{
+			"sessionKey": r.sessionKey,
+			"runId":      runID,
+		})
+		if err != nil {
+			c.log.Warn("abort rejected", "run", runID, "err", err)
+		}
+	}()
+	return partial, nil
+}
+
+func (c *Client) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Close disconnects, waits for the client's goroutines to exit and
+// closes Events. The client can't be connected again.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	err := c.disconnect()
+	close(c.events)
+	return err
+}
+
+// disconnect drops the connection, stops reconnecting and waits for the
+// client's goroutines to exit.
+func (c *Client) disconnect() error {
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+		c.life, c.cancel = nil, nil
+	}
+	c.connected = false
+	c.failPendingLocked()
+
+	var err error
+	if c.conn != nil {
+		err = c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	return err
+}
+
+// Reconnect drops the connection and dials again; ctx bounds the dial.
+func (c *Client) Reconnect(ctx context.Context) error {
+	c.disconnect()
+	return c.Connect(ctx)
+}
@@ -0,0 +1,164 @@
+package openclaw_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// blackhole accepts TCP connections and never answers, like a gateway
+// that hangs before the websocket upgrade. It returns its ws:// URL.
+func blackhole(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		mu.Lock()
+		for _, c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	})
+	return "ws://" + l.Addr().String()
+}
+
+func TestConnectContext(t *testing.T) {
+	c, err := openclaw.New(blackhole(t), openclaw.WithIdentityFile(gatewaytest.IdentityFile(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	// The deadline shows as a context or an I/O timeout, depending on
+	// where the dial was
+	if err := c.Connect(ctx); err == nil {
+		t.Fatal("connected to a black hole")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("connect took %v, past the context's deadline", elapsed)
+	}
+	if c.IsConnected() {
+		t.Error("connected to a black hole")
+	}
+}
+
+func TestSendContext(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	c := srv.Client(t)
+
+	// Frames wait while the server stalls, so the send isn't answered
+	srv.Stall()
+	defer srv.Resume()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.Send(ctx, openclaw.NewRunID(), "main", "hi")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the context's deadline", err)
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(gatewaytest.Reply("a", "b", "c"))
+	identity := gatewaytest.IdentityFile(t)
+	hole := blackhole(t)
+
+	// Let the servers settle before counting
+	time.Sleep(50 * time.Millisecond)
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		c, err := openclaw.New(srv.URL,
+			openclaw.WithIdentityFile(identity),
+			openclaw.WithHeartbeat(50*time.Millisecond, time.Second),
+			openclaw.WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		for ev := range c.Events() {
+			if _, ok := ev.(openclaw.Connected); ok {
+				break
+			}
+		}
+		runID := openclaw.NewRunID()
+		c.Send(context.Background(), runID, "main", "hi")
+		c.Abort(runID)
+		srv.Drop()
+		c.Close()
+
+		c, err = openclaw.New(hole, openclaw.WithIdentityFile(identity))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		c.Connect(ctx)
+		cancel()
+		c.Close()
+	}
+
+	// Server-side connection handlers may take a moment to notice
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%d goroutines before, %d after:\n%s", before, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	const goroutines, each = 8, 1000
+	ids := make(chan string, goroutines*each)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				ids <- openclaw.NewRunID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool)
+	for id := range ids {
+		if len(id) != len("molt-")+32 || seen[id] {
+			t.Fatalf("got %q, want a fresh molt- key with 32 hex digits", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,29 @@
+// Package openclaw is a client for the OpenClaw gateway WebSocket
+// protocol: the signed device handshake, automatic reconnects, chat runs
+// and the gateway's request/response methods.
+//
+// Output of runs and changes in connection state arrive as typed events
+// on a channel:
+//
+//	c, err := openclaw.New("ws://127.0.0.1:18789", openclaw.WithToken(token))
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	if err := c.Connect(ctx); err != nil {
+//		return err
+//	}
+//	for ev := range c.Events() {
+//		switch ev := ev.(type) {
+//		case openclaw.Connected:
+//			go c.Send(ctx, openclaw.NewRunID(), openclaw.DefaultSessionKey, "Hello")
+//		case openclaw.Delta:
+//			fmt.Print(ev.Delta)
+//		case openclaw.Final:
+//			return nil
+//		}
+//	}
+//
+// The client needs a device identity approved by the gateway; see
+// GenerateIdentity and SaveIdentity.
+package openclaw
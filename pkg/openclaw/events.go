@@ -0,0 +1,143 @@
+package openclaw
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is delivered on Client.Events. Use a type switch on the concrete
+// types below; new event types may be added.
+type Event interface {
+	event()
+}
+
+// Connected is sent each time the connect handshake completes, including
+// after an automatic reconnect.
+type Connected struct {
+	Hello *Hello
+}
+
+// Reconnecting is sent before each redial attempt with the delay the
+// client waits first.
+type Reconnecting struct {
+	Attempt int
+	Delay   time.Duration
+}
+
+// ConnectionError reports a dropped connection or a rejected handshake.
+// Dropped connections are redialed automatically.
+type ConnectionError struct {
+	Err error
+}
+
+// PairingRequired is sent when the gateway doesn't know the device yet.
+// The client keeps retrying until the device is approved.
+type PairingRequired struct {
+	DeviceID    string
+	Fingerprint string
+}
+
+// RunEvent is an Event that belongs to a run started with Send. Every run
+// ends with exactly one Final, Error or Aborted, unless it is stopped with
+// Abort or the client is closed.
+type RunEvent interface {
+	Event
+	Run() string
+}
+
+// Delta carries new answer text of a run. Text is everything received
+// so far.
+type Delta struct {
+	RunID string
+	Delta string
+	Text  string
+}
+
+// Replace carries the whole answer text of a run when the gateway revised
+// text that was already delivered, which a Delta can't express. Text
+// replaces everything received so far.
+type Replace struct {
+	RunID string
+	Text  string
+}
+
+// Final ends a run that completed normally.
+type Final struct {
+	RunID      string
+	Text       string
+	StopReason string // As reported by the model, e.g. "stop" or "length"
+	Usage      *Usage // Nil if the gateway didn't report it
+}
+
+// Usage is the token count of a run.
+type Usage struct {
+	Input      int `json:"input"`
+	Output     int `json:"output"`
+	CacheRead  int `json:"cacheRead,omitempty"`
+	CacheWrite int `json:"cacheWrite,omitempty"`
+	Total      int `json:"totalTokens,omitempty"`
+}
+
+// Error ends a run that failed on the gateway. Text is the answer
+// received before the failure.
+type Error struct {
+	RunID   string
+	Code    string
+	Message string
+	Text    string
+}
+
+// Aborted ends a run stopped on the gateway side. Runs stopped with
+// Abort are dropped locally and produce no further events.
+type Aborted struct {
+	RunID string
+	Text  string // Content received before the abort
+}
+
+// ToolStart reports that the agent called a tool during a run.
+type ToolStart struct {
+	RunID  string
+	CallID string
+	Name   string
+	Args   json.RawMessage
+}
+
+// ToolResult reports the outcome of a tool call announced by ToolStart.
+type ToolResult struct {
+	RunID    string
+	CallID   string
+	Name     string
+	Result   json.RawMessage
+	IsError  bool
+	Duration time.Duration // Since the ToolStart; zero if it wasn't seen
+}
+
+// Thinking carries new reasoning text of a run, for models that stream
+// it. It is not part of the answer.
+type Thinking struct {
+	RunID string
+	Delta string
+	Text  string
+}
+
+func (Connected) event()       {}
+func (Reconnecting) event()    {}
+func (ConnectionError) event() {}
+func (PairingRequired) event() {}
+func (Delta) event()           {}
+func (Replace) event()         {}
+func (Final) event()           {}
+func (Error) event()           {}
+func (Aborted) event()         {}
+func (ToolStart) event()       {}
+func (ToolResult) event()      {}
+func (Thinking) event()        {}
+
+func (e Delta) Run() string      { return e.RunID }
+func (e Replace) Run() string    { return e.RunID }
+func (e Final) Run() string      { return e.RunID }
+func (e Error) Run() string      { return e.RunID }
+func (e Aborted) Run() string    { return e.RunID }
+func (e ToolStart) Run() string  { return e.RunID }
+func (e ToolResult) Run() string { return e.RunID }
+func (e Thinking) Run() string   { return e.RunID }
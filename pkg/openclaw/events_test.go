@@ -0,0 +1,70 @@
+package openclaw_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+func TestEventStream(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(gatewaytest.Reply("Hello", " world"))
+	c := srv.Client(t, openclaw.WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+
+	runID := openclaw.NewRunID()
+	if err := c.Send(context.Background(), runID, "main", "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run, a dropped connection and the reconnect, then Close; every
+	// event arrives in order and the channel is closed at the end
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev, ok := <-c.Events():
+			if !ok {
+				done = true
+				break
+			}
+			switch ev := ev.(type) {
+			case openclaw.Delta:
+				got = append(got, "delta "+ev.Delta)
+			case openclaw.Final:
+				got = append(got, "final "+ev.Text)
+				srv.Drop()
+			case openclaw.ConnectionError:
+				got = append(got, "connection error")
+			case openclaw.Reconnecting:
+				got = append(got, "reconnecting")
+			case openclaw.Connected:
+				got = append(got, "connected")
+				go c.Close()
+			}
+		case <-timeout:
+			t.Fatalf("events not closed; got %q", got)
+		}
+	}
+
+	want := []string{"delta Hello", "delta  world", "final Hello world", "connection error", "reconnecting", "connected"}
+	if len(got) != len(want) {
+		t.Fatalf("got events %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if err := c.Connect(context.Background()); !errors.Is(err, openclaw.ErrClosed) {
+		t.Errorf("connect after close: got %v, want ErrClosed", err)
+	}
+	if err := c.Send(context.Background(), openclaw.NewRunID(), "main", "hi"); !errors.Is(err, openclaw.ErrNotConnected) {
+		t.Errorf("send after close: got %v, want ErrNotConnected", err)
+	}
+}
@@ -0,0 +1,102 @@
+package openclaw
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Default heartbeat settings, used without WithHeartbeat.
+const (
+	DefaultPingInterval = 15 * time.Second
+	DefaultPongTimeout  = 45 * time.Second
+)
+
+// HeartbeatStats describes the last sign of life from the gateway.
+type HeartbeatStats struct {
+	LastBeat time.Time     // Last pong or tick event; zero if none yet
+	RTT      time.Duration // Round trip of the last answered ping
+}
+
+// WithHeartbeat sets how often the client pings the gateway and how long
+// it waits without hearing anything before treating the connection as
+// dead. An interval of zero disables pings and read deadlines.
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.pingInterval = interval
+		if timeout > 0 {
+			c.pongTimeout = timeout
+		}
+		if c.pongTimeout <= c.pingInterval {
+			c.pongTimeout = 3 * c.pingInterval
+		}
+		return nil
+	}
+}
+
+// Heartbeat returns the last heartbeat time and ping round trip.
+func (c *Client) Heartbeat() HeartbeatStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return HeartbeatStats{LastBeat: c.lastBeat, RTT: c.rtt}
+}
+
+// startHeartbeat arms the read deadline on conn and pings it until stop
+// is closed. A missed deadline fails the read in readLoop, which then
+// reconnects like any other dropped connection.
+func (c *Client) startHeartbeat(conn *websocket.Conn, stop chan struct{}) {
+	if c.pingInterval <= 0 {
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	conn.SetPongHandler(func(data string) error {
+		now := time.Now()
+		c.mu.Lock()
+		c.lastBeat = now
+		if sent, err := strconv.ParseInt(data, 10, 64); err == nil {
+			c.rtt = now.Sub(time.Unix(0, sent))
+		}
+		c.mu.Unlock()
+		return conn.SetReadDeadline(now.Add(c.pongTimeout))
+	})
+
+	// The caller holds a count on c.wg, so Close can't be waiting yet
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				payload := strconv.FormatInt(time.Now().UnixNano(), 10)
+				// WriteControl may run concurrently with WriteJSON
+				deadline := time.Now().Add(c.pingInterval)
+				if err := conn.WriteControl(websocket.PingMessage, []byte(payload), deadline); err != nil {
+					c.log.Debug("ping failed", "err", err)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// alive extends the read deadline after any frame from the gateway; tick
+// events also count as a heartbeat.
+func (c *Client) alive(conn *websocket.Conn, tick bool) {
+	if c.pingInterval <= 0 {
+		return
+	}
+	now := time.Now()
+	if tick {
+		c.mu.Lock()
+		c.lastBeat = now
+		c.mu.Unlock()
+	}
+	conn.SetReadDeadline(now.Add(c.pongTimeout))
+}
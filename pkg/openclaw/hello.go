@@ -0,0 +1,85 @@
+package openclaw
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Protocol is the only gateway protocol version this client speaks. It is
+// sent as both ends of the range the gateway picks from, and the hello
+// must report it.
+const Protocol = 3
+
+// Hello is the gateway's answer to connect ("hello-ok").
+type Hello struct {
+	Protocol int `json:"protocol"`
+	Server   struct {
+		Version string `json:"version"`
+		Commit  string `json:"commit,omitempty"`
+		Host    string `json:"host,omitempty"`
+		ConnID  string `json:"connId,omitempty"`
+	} `json:"server"`
+	Features struct {
+		Methods []string `json:"methods,omitempty"`
+		Events  []string `json:"events,omitempty"`
+	} `json:"features"`
+	Snapshot struct {
+		SessionDefaults SessionDefaults `json:"sessionDefaults"`
+	} `json:"snapshot"`
+	Policy struct {
+		MaxPayload     int `json:"maxPayload,omitempty"`
+		TickIntervalMs int `json:"tickIntervalMs,omitempty"`
+	} `json:"policy"`
+}
+
+// SessionDefaults describes the gateway's default agent and session.
+type SessionDefaults struct {
+	DefaultAgentID string `json:"defaultAgentId,omitempty"`
+	MainKey        string `json:"mainKey,omitempty"`
+	MainSessionKey string `json:"mainSessionKey,omitempty"`
+	Scope          string `json:"scope,omitempty"`
+}
+
+// Supports reports whether the gateway advertised method. Gateways that
+// don't list their methods are assumed to support everything.
+func (h *Hello) Supports(method string) bool {
+	return len(h.Features.Methods) == 0 || slices.Contains(h.Features.Methods, method)
+}
+
+// WithClientVersion sets the version reported in the connect request.
+func WithClientVersion(version string) Option {
+	return func(c *Client) error {
+		c.version = version
+		return nil
+	}
+}
+
+// Hello returns the hello of the current connection, or nil before the
+// handshake completes.
+func (c *Client) Hello() *Hello {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hello
+}
+
+// Supports reports whether the connected gateway advertised method.
+// Before the handshake it returns true, letting the call fail normally.
+func (c *Client) Supports(method string) bool {
+	hello := c.Hello()
+	return hello == nil || hello.Supports(method)
+}
+
+func parseHello(payload json.RawMessage) (*Hello, error) {
+	hello := &Hello{}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, hello); err != nil {
+			return nil, fmt.Errorf("parse hello: %w", err)
+		}
+	}
+	if hello.Protocol != 0 && hello.Protocol != Protocol {
+		return nil, fmt.Errorf("gateway chose protocol %d, client supports %d",
+			hello.Protocol, Protocol)
+	}
+	return hello, nil
+}
@@ -0,0 +1,138 @@
+package openclaw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// historyWindow is how many of the newest messages History searches for
+// older ones when the gateway ignores "before".
+const historyWindow = 1000
+
+// ErrPagingUnsupported is returned by History when the gateway ignores
+// "before" and no older messages are within the newest historyWindow.
+var ErrPagingUnsupported = errors.New("gateway can't page chat history back this far")
+
+// HistoryEntry is one message from the gateway's chat history.
+type HistoryEntry struct {
+	Role      string
+	Text      string
+	Timestamp time.Time
+}
+
+type historyPayload struct {
+	Messages []struct {
+		Role      string          `json:"role"`
+		Content   json.RawMessage `json:"content"`
+		Timestamp int64           `json:"timestamp"` // Unix ms
+	} `json:"messages"`
+}
+
+// History fetches up to limit messages of the session, oldest first. If
+// before is non-zero, only messages older than it are returned.
+//
+// Older gateways ignore "before" and return the newest messages. History
+// then looks for the older ones among the newest historyWindow, and
+// returns ErrPagingUnsupported if there are more than that and none of
+// them is older.
+func (c *Client) History(ctx context.Context, sessionKey string, limit int, before time.Time) ([]HistoryEntry, error) {
+	entries, complete, err := c.fetchHistory(ctx, sessionKey, limit, before)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil && !complete {
+		if limit > 0 && limit < historyWindow {
+			c.log.Debug("gateway ignored before; fetching a wider window", "session", sessionKey, "limit", limit)
+			entries, complete, err = c.fetchHistory(ctx, sessionKey, historyWindow, before)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if entries == nil && !complete {
+			return nil, ErrPagingUnsupported
+		}
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// fetchHistory makes one chat.history request and returns the messages
+// older than before. complete reports whether the gateway applied
+// "before" or returned the whole history, so that the result can be
+// trusted even if it is empty. A page that ignored "before" and has none
+// older is returned as nil.
+func (c *Client) fetchHistory(ctx context.Context, sessionKey string, limit int, before time.Time) (entries []HistoryEntry, complete bool, err error) {
+	params := map[string]interface{}{
+		"sessionKey": sessionKey,
+	}
+	if limit > 0 {
+		params["limit"] = limit
+	}
+	if !before.IsZero() {
+		params["before"] = before.UnixMilli()
+	}
+
+	raw, err := c.Call(ctx, "chat.history", params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var payload historyPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, false, fmt.Errorf("parse chat history: %w", err)
+	}
+
+	complete = true
+	entries = make([]HistoryEntry, 0, len(payload.Messages))
+	for _, msg := range payload.Messages {
+		ts := time.UnixMilli(msg.Timestamp)
+		if !before.IsZero() && !ts.Before(before) {
+			// Older gateways ignore "before"
+			complete = false
+			continue
+		}
+		entries = append(entries, HistoryEntry{
+			Role:      msg.Role,
+			Text:      contentText(msg.Content),
+			Timestamp: ts,
+		})
+	}
+
+	// A short page is everything there is, whatever the gateway did
+	if limit <= 0 || len(payload.Messages) < limit {
+		complete = true
+	}
+	if len(entries) == 0 && !complete {
+		return nil, false, nil
+	}
+	return entries, complete, nil
+}
+
+// contentText flattens message content, which is either a plain string or
+// a list of typed parts, into its text.
+func contentText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	for _, part := range parts {
+		if part.Type == "text" {
+			text += part.Text
+		}
+	}
+	return text
+}
@@ -0,0 +1,71 @@
+package openclaw_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// addMessages adds n messages to the main session, a minute apart and
+// ending an hour ago, and returns the time of the first.
+func addMessages(srv *gatewaytest.Server, n int) time.Time {
+	start := time.Now().Add(-time.Hour - time.Duration(n)*time.Minute).Truncate(time.Millisecond)
+	for i := 0; i < n; i++ {
+		srv.AddHistory("main", "user", fmt.Sprint(i), start.Add(time.Duration(i)*time.Minute))
+	}
+	return start
+}
+
+func TestHistoryBefore(t *testing.T) {
+	for _, paged := range []bool{true, false} {
+		t.Run(fmt.Sprintf("paged=%v", paged), func(t *testing.T) {
+			srv := gatewaytest.NewServer()
+			defer srv.Close()
+			srv.PageHistory = paged
+			start := addMessages(srv, 50)
+			c := srv.Client(t)
+
+			// The 10 messages before the 31st
+			entries, err := c.History(context.Background(), "main", 10, start.Add(30*time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 10 || entries[0].Text != "20" || entries[9].Text != "29" {
+				t.Fatalf("got %+v, want messages 20 to 29", entries)
+			}
+
+			entries, err = c.History(context.Background(), "main", 10, start)
+			if err != nil || len(entries) != 0 {
+				t.Fatalf("before the first message: got %+v, %v", entries, err)
+			}
+
+			entries, err = c.History(context.Background(), "main", 10, time.Time{})
+			if err != nil || len(entries) != 10 || entries[9].Text != "49" {
+				t.Fatalf("newest: got %+v, %v", entries, err)
+			}
+		})
+	}
+}
+
+func TestHistoryPagingUnsupported(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	start := addMessages(srv, 1200)
+	c := srv.Client(t)
+
+	// Older than the newest 1000, which is all the client looks through
+	_, err := c.History(context.Background(), "main", 10, start.Add(100*time.Minute))
+	if !errors.Is(err, openclaw.ErrPagingUnsupported) {
+		t.Fatalf("got %v, want ErrPagingUnsupported", err)
+	}
+
+	entries, err := c.History(context.Background(), "main", 10, start.Add(500*time.Minute))
+	if err != nil || len(entries) != 10 || entries[9].Text != "499" {
+		t.Fatalf("within the window: got %+v, %v", entries, err)
+	}
+}
@@ -0,0 +1,148 @@
+package openclaw
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoIdentity is returned when the device identity file doesn't exist.
+var ErrNoIdentity = errors.New("device identity not found")
+
+// DeviceIdentity is the device identity file shared with the OpenClaw CLI.
+// DeviceID is derived from the public key, see DeviceIDFor.
+type DeviceIdentity struct {
+	Version       int    `json:"version"`
+	DeviceID      string `json:"deviceId"`
+	PublicKeyPem  string `json:"publicKeyPem"`
+	PrivateKeyPem string `json:"privateKeyPem"`
+	CreatedAtMs   int64  `json:"createdAtMs,omitempty"`
+}
+
+// DefaultIdentityPath is where the OpenClaw CLI keeps its device identity.
+func DefaultIdentityPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".openclaw", "identity", "device.json"), nil
+}
+
+// DeviceIDFor derives the device ID the gateway expects: the hex SHA-256
+// of the raw ed25519 public key.
+func DeviceIDFor(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateIdentity creates a new ed25519 device identity.
+func GenerateIdentity() (*DeviceIdentity, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("encode private key: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("encode public key: %w", err)
+	}
+
+	return &DeviceIdentity{
+		Version:       1,
+		DeviceID:      DeviceIDFor(pub),
+		PublicKeyPem:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+		PrivateKeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})),
+		CreatedAtMs:   time.Now().UnixMilli(),
+	}, nil
+}
+
+// SaveIdentity writes identity to path with owner-only permissions. It
+// refuses to replace an existing file unless overwrite is set.
+func SaveIdentity(path string, identity *DeviceIdentity, overwrite bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create identity directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !overwrite {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s: %w", path, os.ErrExist)
+		}
+		return fmt.Errorf("write device identity: %w", err)
+	}
+	defer f.Close()
+
+	// OpenFile only applies the mode to new files
+	if err := f.Chmod(0600); err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write device identity: %w", err)
+	}
+	return f.Sync()
+}
+
+// LoadIdentity reads a device identity and its private key, and checks
+// that the device ID is the one derived from the key. A missing file
+// yields an error wrapping ErrNoIdentity.
+func LoadIdentity(path string) (*DeviceIdentity, ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("%w at %s", ErrNoIdentity, path)
+		}
+		return nil, nil, fmt.Errorf("read device identity: %w", err)
+	}
+
+	var identity DeviceIdentity
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, nil, fmt.Errorf("parse device identity: %w", err)
+	}
+
+	block, _ := pem.Decode([]byte(identity.PrivateKeyPem))
+	if block == nil {
+		return nil, nil, fmt.Errorf("decode private key PEM")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("not ed25519 key")
+	}
+
+	if identity.DeviceID == "" {
+		return nil, nil, fmt.Errorf("device identity has no deviceId")
+	}
+	// The gateway derives the ID from the key and rejects any other
+	if want := DeviceIDFor(edKey.Public().(ed25519.PublicKey)); identity.DeviceID != want {
+		return nil, nil, fmt.Errorf("device identity: deviceId %q doesn't match the key (%s)", identity.DeviceID, want)
+	}
+
+	return &identity, edKey, nil
+}
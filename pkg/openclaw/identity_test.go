@@ -0,0 +1,58 @@
+package openclaw
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadIdentity(t *testing.T) {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "device.json")
+	if err := SaveIdentity(path, identity, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveIdentity(path, identity, false); !errors.Is(err, os.ErrExist) {
+		t.Errorf("saving over an identity: got %v, want ErrExist", err)
+	}
+
+	loaded, key, err := LoadIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.DeviceID != identity.DeviceID || DeviceIDFor(key.Public().(ed25519.PublicKey)) != identity.DeviceID {
+		t.Errorf("loaded %s, want %s", loaded.DeviceID, identity.DeviceID)
+	}
+
+	if _, _, err := LoadIdentity(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("missing file: got %v, want ErrNoIdentity", err)
+	}
+}
+
+func TestLoadIdentityMismatch(t *testing.T) {
+	other, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, deviceID := range []string{"", "abc", other.DeviceID} {
+		identity, err := GenerateIdentity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		identity.DeviceID = deviceID
+		path := filepath.Join(t.TempDir(), "device.json")
+		if err := SaveIdentity(path, identity, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := LoadIdentity(path); err == nil || !strings.Contains(err.Error(), "deviceId") {
+			t.Errorf("deviceId %q: got %v, want it rejected", deviceID, err)
+		}
+	}
+}
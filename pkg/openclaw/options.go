@@ -0,0 +1,95 @@
+package openclaw
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Option configures a Client in New.
+type Option func(*Client) error
+
+// DefaultEventBuffer is how many events may queue on Events before the
+// client stops reading from the gateway.
+const DefaultEventBuffer = 64
+
+// WithToken sets the gateway auth token.
+func WithToken(token string) Option {
+	return func(c *Client) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithIdentityFile signs in with the device identity stored at path
+// instead of DefaultIdentityPath.
+func WithIdentityFile(path string) Option {
+	return func(c *Client) error {
+		c.identityPath = path
+		return nil
+	}
+}
+
+// WithBackoff sets the reconnect delay bounds. Zero values keep the
+// defaults.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(c *Client) error {
+		if initial > 0 {
+			c.initialDelay = initial
+		}
+		if max > 0 {
+			c.maxDelay = max
+		}
+		if c.maxDelay < c.initialDelay {
+			c.maxDelay = c.initialDelay
+		}
+		return nil
+	}
+}
+
+// WithConnectRetry makes the client keep redialing in the background, as
+// after a dropped connection, when the dial in Connect fails. Connect
+// still returns the error, which is also sent as a ConnectionError. There
+// is no retry if ctx is done or the client closed.
+func WithConnectRetry() Option {
+	return func(c *Client) error {
+		c.connectRetry = true
+		return nil
+	}
+}
+
+// WithLogger sets the logger for connection and run diagnostics. The
+// default discards them.
+func WithLogger(log *slog.Logger) Option {
+	return func(c *Client) error {
+		if log == nil {
+			return errors.New("nil logger")
+		}
+		c.log = log
+		return nil
+	}
+}
+
+// WithReorderWindow sets how long a chat event that skips a seq is held
+// for the missing ones; see DefaultReorderWindow. Zero applies events as
+// they arrive, still dropping those older than one already applied.
+func WithReorderWindow(d time.Duration) Option {
+	return func(c *Client) error {
+		if d < 0 {
+			return errors.New("negative reorder window")
+		}
+		c.reorder = d
+		return nil
+	}
+}
+
+// WithEventBuffer sets the capacity of the Events channel.
+func WithEventBuffer(n int) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return errors.New("negative event buffer")
+		}
+		c.eventBuffer = n
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package openclaw
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultPairingRetry is how often the client retries connect while the
+// device waits for approval.
+const DefaultPairingRetry = 5 * time.Second
+
+// Fingerprint returns an SSH-style SHA256 fingerprint of the public key,
+// for comparing against what the gateway shows when approving a device.
+func Fingerprint(pub ed25519.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		der = pub
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// WithPairingRetry sets how often connect is retried while awaiting
+// approval.
+func WithPairingRetry(d time.Duration) Option {
+	return func(c *Client) error {
+		if d > 0 {
+			c.pairingRetry = d
+		}
+		return nil
+	}
+}
+
+// AwaitingPairing reports whether the device is waiting for approval.
+func (c *Client) AwaitingPairing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pairing
+}
+
+// isPairingRequired recognizes the gateway's rejection of an unapproved
+// device. Gateways differ in code, so the message is checked as well.
+func isPairingRequired(ferr *FrameError) bool {
+	if ferr == nil {
+		return false
+	}
+	if code, ok := ferr.Code.(string); ok && strings.Contains(strings.ToUpper(code), "PAIR") {
+		return true
+	}
+	msg := strings.ToLower(ferr.Message)
+	return strings.Contains(msg, "pairing") || strings.Contains(msg, "not paired")
+}
+
+func (c *Client) handlePairingRequired() {
+	c.mu.Lock()
+	first := !c.pairing
+	c.pairing = true
+	conn := c.conn
+	c.mu.Unlock()
+
+	if first {
+		pub := c.privateKey.Public().(ed25519.PublicKey)
+		c.log.Warn("device not paired, waiting for approval", "device", c.deviceID)
+		c.emit(PairingRequired{DeviceID: c.deviceID, Fingerprint: Fingerprint(pub)})
+	}
+
+	// Drop the connection; readLoop sees the pairing state and retries
+	// with a fresh challenge after pairingRetry
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// pairingLoop redials every pairingRetry until the connection is up or the
+// client is closed. Unlike reconnectLoop it reports nothing per attempt.
+func (c *Client) pairingLoop(life context.Context) {
+	for {
+		select {
+		case <-time.After(c.pairingRetry):
+		case <-life.Done():
+			return
+		}
+
+		err := c.dial(life, life)
+		if err == nil || errors.Is(err, ErrClosed) {
+			return
+		}
+		c.log.Debug("pairing retry failed", "err", fmt.Sprint(err))
+	}
+}
@@ -0,0 +1,156 @@
+package openclaw
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ContentPart is one element of a chat message's content. Besides text,
+// agents emit reasoning and tool calls with their results; the gateway
+// passes on the field names of the model provider, so both the camelCase
+// and snake_case spellings are accepted.
+type ContentPart struct {
+	Type string `json:"type"` // "text", "thinking", "toolCall"/"tool_use", "toolResult"/"tool_result"
+	Text string `json:"text,omitempty"`
+
+	// "thinking"
+	Thinking string `json:"thinking,omitempty"`
+
+	// Tool call
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+
+	// Tool result
+	ToolCallID   string          `json:"toolCallId,omitempty"`
+	ToolUseID    string          `json:"tool_use_id,omitempty"`
+	ToolName     string          `json:"toolName,omitempty"`
+	Content      json.RawMessage `json:"content,omitempty"`
+	IsError      bool            `json:"isError,omitempty"`
+	IsErrorSnake bool            `json:"is_error,omitempty"`
+}
+
+// IsToolCall reports whether the part is a tool call made by the agent.
+func (p ContentPart) IsToolCall() bool {
+	return p.Type == "toolCall" || p.Type == "tool_use" || p.Type == "toolUse"
+}
+
+// IsToolResult reports whether the part is the result of a tool call.
+func (p ContentPart) IsToolResult() bool {
+	return p.Type == "toolResult" || p.Type == "tool_result"
+}
+
+// CallID returns the ID of the tool call the part makes or answers.
+func (p ContentPart) CallID() string {
+	switch {
+	case p.ToolCallID != "":
+		return p.ToolCallID
+	case p.ToolUseID != "":
+		return p.ToolUseID
+	}
+	return p.ID
+}
+
+// Args returns the arguments of a tool call.
+func (p ContentPart) Args() json.RawMessage {
+	if len(p.Arguments) > 0 {
+		return p.Arguments
+	}
+	return p.Input
+}
+
+// Failed reports whether a tool result is an error.
+func (p ContentPart) Failed() bool {
+	return p.IsError || p.IsErrorSnake
+}
+
+// toolCall is a tool call of a run, recorded so that calls reported both
+// by "agent" events and in message content are surfaced once.
+type toolCall struct {
+	name    string
+	started time.Time
+	done    bool
+}
+
+// toolStart records a tool call and reports whether it is new. c.mu must
+// be held.
+func (r *run) toolStart(runID, callID, name string, args json.RawMessage) (ToolStart, bool) {
+	if _, ok := r.tools[callID]; ok {
+		return ToolStart{}, false
+	}
+	if r.tools == nil {
+		r.tools = make(map[string]*toolCall)
+	}
+	r.tools[callID] = &toolCall{name: name, started: time.Now()}
+	return ToolStart{RunID: runID, CallID: callID, Name: name, Args: args}, true
+}
+
+// toolResult records the end of a tool call and reports whether it wasn't
+// already. A result whose call was never seen has no duration. c.mu must
+// be held.
+func (r *run) toolResult(runID, callID, name string, result json.RawMessage, isError bool) (ToolResult, bool) {
+	ev := ToolResult{RunID: runID, CallID: callID, Name: name, Result: result, IsError: isError}
+	call, ok := r.tools[callID]
+	switch {
+	case !ok:
+		if r.tools == nil {
+			r.tools = make(map[string]*toolCall)
+		}
+		r.tools[callID] = &toolCall{name: name, done: true}
+	case call.done:
+		return ToolResult{}, false
+	default:
+		call.done = true
+		ev.Duration = time.Since(call.started)
+		if ev.Name == "" {
+			ev.Name = call.name
+		}
+	}
+	return ev, true
+}
+
+// think records the reasoning text of a run, given as the accumulated text,
+// a delta, or both, and returns what is new. c.mu must be held.
+func (r *run) think(runID, text, delta string) (Thinking, bool) {
+	switch {
+	case text == "":
+		text = r.thinking + delta
+	case delta == "" && strings.HasPrefix(text, r.thinking):
+		delta = text[len(r.thinking):]
+	case delta == "":
+		// Shorter or rewritten; nothing new to show
+		return Thinking{}, false
+	}
+	r.thinking = text
+	return Thinking{RunID: runID, Delta: delta, Text: text}, delta != ""
+}
+
+// activity returns the reasoning and tool events for the non-text parts of
+// a chat message that weren't reported yet. c.mu must be held.
+func (r *run) activity(runID string, parts []ContentPart) []Event {
+	var events []Event
+	var thinking string
+	for _, part := range parts {
+		switch {
+		case part.Type == "thinking":
+			thinking += part.Thinking
+		case part.IsToolCall():
+			if ev, ok := r.toolStart(runID, part.CallID(), part.Name, part.Args()); ok {
+				events = append(events, ev)
+			}
+		case part.IsToolResult():
+			if ev, ok := r.toolResult(runID, part.CallID(), part.ToolName, part.Content, part.Failed()); ok {
+				events = append(events, ev)
+			}
+		}
+	}
+	// Reasoning precedes the calls it leads to
+	if thinking != "" && len(thinking) > len(r.thinking) {
+		if ev, ok := r.think(runID, thinking, ""); ok {
+			events = append([]Event{ev}, events...)
+		}
+	}
+	return events
+}
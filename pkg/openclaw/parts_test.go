@@ -0,0 +1,148 @@
+package openclaw
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// activityStep feeds one chat event or agent event to a run and returns
+// the events it adds.
+type activityStep func(r *run) []Event
+
+// snapshot is a chat event with the given message content.
+func snapshot(parts ...ContentPart) activityStep {
+	return func(r *run) []Event { return r.activity("run-1", parts) }
+}
+
+// thinkingEvent is an agent event on the "thinking" stream.
+func thinkingEvent(text, delta string) activityStep {
+	return func(r *run) []Event {
+		if ev, ok := r.think("run-1", text, delta); ok {
+			return []Event{ev}
+		}
+		return nil
+	}
+}
+
+// toolEvent is an agent event on the "tool" stream.
+func toolEvent(phase, callID, name string) activityStep {
+	return func(r *run) []Event {
+		var ev Event
+		var ok bool
+		if phase == "start" {
+			ev, ok = r.toolStart("run-1", callID, name, nil)
+		} else {
+			ev, ok = r.toolResult("run-1", callID, name, nil, false)
+		}
+		if ok {
+			return []Event{ev}
+		}
+		return nil
+	}
+}
+
+var (
+	callPart   = ContentPart{Type: "toolCall", ID: "call-1", Name: "exec", Arguments: json.RawMessage(`{"cmd":"ls"}`)}
+	resultPart = ContentPart{Type: "toolResult", ToolCallID: "call-1", ToolName: "exec", Content: json.RawMessage(`"a.go"`)}
+	textPart   = ContentPart{Type: "text", Text: "Done."}
+)
+
+// timed stands for the duration of a tool result whose start was seen.
+const timed = time.Nanosecond
+
+func TestRunActivity(t *testing.T) {
+	start := ToolStart{RunID: "run-1", CallID: "call-1", Name: "exec", Args: callPart.Arguments}
+	result := ToolResult{RunID: "run-1", CallID: "call-1", Name: "exec", Result: resultPart.Content, Duration: timed}
+
+	tests := []struct {
+		name  string
+		steps []activityStep
+		want  [][]Event // Per step
+	}{
+		{
+			name:  "tool call repeated in snapshots",
+			steps: []activityStep{snapshot(callPart), snapshot(callPart, resultPart), snapshot(callPart, resultPart, textPart)},
+			want:  [][]Event{{start}, {result}, nil},
+		},
+		{
+			name: "snake_case parts",
+			steps: []activityStep{
+				snapshot(ContentPart{Type: "tool_use", ID: "call-1", Name: "exec", Input: callPart.Arguments}),
+				snapshot(ContentPart{Type: "tool_result", ToolUseID: "call-1", Content: resultPart.Content, IsErrorSnake: true}),
+			},
+			want: [][]Event{{start}, {ToolResult{RunID: "run-1", CallID: "call-1", Name: "exec", Result: resultPart.Content, IsError: true, Duration: timed}}},
+		},
+		{
+			name:  "agent events and snapshots",
+			steps: []activityStep{toolEvent("start", "call-1", "exec"), snapshot(callPart), toolEvent("result", "call-1", "exec"), snapshot(callPart, resultPart)},
+			want:  [][]Event{{ToolStart{RunID: "run-1", CallID: "call-1", Name: "exec"}}, nil, {ToolResult{RunID: "run-1", CallID: "call-1", Name: "exec", Duration: timed}}, nil},
+		},
+		{
+			name:  "result without its call",
+			steps: []activityStep{snapshot(resultPart), snapshot(callPart, resultPart)},
+			want:  [][]Event{{ToolResult{RunID: "run-1", CallID: "call-1", Name: "exec", Result: resultPart.Content}}, nil},
+		},
+		{
+			name: "thinking in snapshots",
+			steps: []activityStep{
+				snapshot(ContentPart{Type: "thinking", Thinking: "Let"}),
+				snapshot(ContentPart{Type: "thinking", Thinking: "Let me"}),
+				snapshot(ContentPart{Type: "thinking", Thinking: "Let me"}, textPart),
+			},
+			want: [][]Event{
+				{Thinking{RunID: "run-1", Delta: "Let", Text: "Let"}},
+				{Thinking{RunID: "run-1", Delta: " me", Text: "Let me"}},
+				nil,
+			},
+		},
+		{
+			name:  "thinking split over parts",
+			steps: []activityStep{snapshot(ContentPart{Type: "thinking", Thinking: "a"}, ContentPart{Type: "thinking", Thinking: "b"})},
+			want:  [][]Event{{Thinking{RunID: "run-1", Delta: "ab", Text: "ab"}}},
+		},
+		{
+			name: "thinking streamed, then in snapshots",
+			steps: []activityStep{
+				thinkingEvent("", "Let"),
+				thinkingEvent("", " me"),
+				snapshot(ContentPart{Type: "thinking", Thinking: "Let me"}, callPart),
+				thinkingEvent("Let me see", ""),
+			},
+			want: [][]Event{
+				{Thinking{RunID: "run-1", Delta: "Let", Text: "Let"}},
+				{Thinking{RunID: "run-1", Delta: " me", Text: "Let me"}},
+				{start},
+				{Thinking{RunID: "run-1", Delta: " see", Text: "Let me see"}},
+			},
+		},
+		{
+			name:  "thinking before the call it leads to",
+			steps: []activityStep{snapshot(callPart, ContentPart{Type: "thinking", Thinking: "hm"})},
+			want:  [][]Event{{Thinking{RunID: "run-1", Delta: "hm", Text: "hm"}, start}},
+		},
+		{
+			name:  "thinking rewritten",
+			steps: []activityStep{thinkingEvent("abc", ""), thinkingEvent("xyz", ""), thinkingEvent("", "!")},
+			want:  [][]Event{{Thinking{RunID: "run-1", Delta: "abc", Text: "abc"}}, nil, {Thinking{RunID: "run-1", Delta: "!", Text: "abc!"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &run{}
+			for i, step := range tt.steps {
+				got := step(r)
+				for j, ev := range got {
+					if res, ok := ev.(ToolResult); ok && res.Duration > 0 {
+						res.Duration = timed
+						got[j] = res
+					}
+				}
+				if !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("step %d: got %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
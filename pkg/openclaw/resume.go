@@ -0,0 +1,208 @@
+package openclaw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CodeRunLost is the Error code of a run that was streaming when the
+// connection dropped and couldn't be picked up again after reconnecting.
+const CodeRunLost = "RUN_LOST"
+
+// resumeHistoryLimit is how many messages of the session are searched for
+// the reply of a run that ended while the client was disconnected.
+const resumeHistoryLimit = 20
+
+// runEnded reports whether a chat.send status says the run is over.
+func runEnded(status string) bool {
+	switch status {
+	case "ok", "final", "done", "error", "aborted":
+		return true
+	}
+	return false
+}
+
+// startResumeLocked starts resumeRuns if the gateway had accepted runs
+// that are still unfinished, and reports whether it did. c.mu must be
+// held.
+func (c *Client) startResumeLocked() bool {
+	if c.life == nil {
+		return false
+	}
+	var ids []string
+	for id, r := range c.runs {
+		if r.accepted {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return false
+	}
+	sort.Strings(ids)
+
+	life := c.life
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.resumeRuns(life, ids)
+	}()
+	return true
+}
+
+// resumeRuns picks up runs that were streaming when the connection
+// dropped; whatever the gateway sent meanwhile is lost. Each chat.send is
+// repeated with the same idempotency key, so the gateway doesn't run the
+// message again but says how the run is doing. A run that is still going
+// streams on: chat events carry the whole text, so the next one fills in
+// what was missed and only the new part is emitted. A run that ended is
+// settled from the session history. If the gateway forgot the key, the
+// run it started anew is aborted and the run is lost.
+func (c *Client) resumeRuns(life context.Context, ids []string) {
+	for _, runID := range ids {
+		if !c.resumeRun(life, runID) {
+			return
+		}
+	}
+}
+
+// resumeRun resumes one run and reports whether the connection is still
+// usable for the rest.
+func (c *Client) resumeRun(life context.Context, runID string) bool {
+	c.mu.Lock()
+	r, ok := c.runs[runID]
+	c.mu.Unlock()
+	if !ok {
+		// Finished or aborted since
+		return true
+	}
+
+	status, err := c.sendRun(life, runID, r)
+	switch {
+	case errors.Is(err, ErrConnectionLost) || errors.Is(err, ErrNotConnected) || life.Err() != nil:
+		// Dropped again; the next connect tries again
+		return false
+	case err != nil:
+		c.failRun(runID, fmt.Sprintf("resume after reconnect: %v", err))
+		return true
+	}
+	c.log.Info("resumed run", "run", runID, "status", status)
+
+	switch {
+	case runEnded(status):
+		c.settleRun(life, runID, status)
+	case status == "started":
+		// The gateway no longer knew the key and started the message
+		// again. What the lost run did can't be told, so stop the new one
+		// rather than answer twice
+		c.failRun(runID, "gateway no longer knows the run")
+		if _, err := c.Call(life, "chat.abort", map[string]interface{}{
+			"sessionKey": r.sessionKey,
+			"runId":      runID,
+		}); err != nil {
+			c.log.Warn("abort restarted run", "run", runID, "err", err)
+		}
+	}
+	return true
+}
+
+// settleRun finishes a run that ended while its events couldn't reach the
+// client.
+func (c *Client) settleRun(ctx context.Context, runID, status string) {
+	switch status {
+	case "error":
+		c.failRun(runID, "run failed while disconnected from the gateway")
+		return
+	case "aborted":
+		c.applyRecovered(runID, &chatEvent{RunID: runID, State: "aborted"})
+		return
+	}
+
+	reply, err := c.fetchReply(ctx, runID)
+	if err != nil {
+		c.failRun(runID, fmt.Sprintf("run ended while disconnected and its reply can't be fetched: %v", err))
+		return
+	}
+	final := &chatEvent{RunID: runID, State: "final"}
+	if reply != "" {
+		final.Message.Content = []ContentPart{{Type: "text", Text: reply}}
+	}
+	c.applyRecovered(runID, final)
+}
+
+// fetchReply returns the reply of a finished run from the history of its
+// session: the assistant text after the latest user message that matches
+// what the run sent.
+func (c *Client) fetchReply(ctx context.Context, runID string) (string, error) {
+	c.mu.Lock()
+	r, ok := c.runs[runID]
+	c.mu.Unlock()
+	if !ok {
+		return "", ErrNoActiveRun
+	}
+	if !c.Supports("chat.history") {
+		return "", errors.New("gateway has no chat.history")
+	}
+
+	entries, err := c.History(ctx, r.sessionKey, resumeHistoryLimit, time.Time{})
+	if err != nil {
+		return "", err
+	}
+
+	var reply string
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		switch {
+		case e.Role == "assistant" && reply == "":
+			reply = e.Text
+		case e.Role == "user" && e.Text == r.message:
+			return reply, nil
+		case e.Role == "user":
+			// A later message; what came after it isn't our reply
+			reply = ""
+		}
+	}
+	return "", errors.New("message not in history")
+}
+
+// applyRecovered applies an event made up for a run whose own events were
+// lost, unless the run finished meanwhile.
+func (c *Client) applyRecovered(runID string, ev *chatEvent) {
+	c.chatMu.Lock()
+	defer c.chatMu.Unlock()
+
+	c.mu.Lock()
+	r, ok := c.runs[runID]
+	if ok {
+		r.held = nil
+	}
+	c.mu.Unlock()
+
+	if ok {
+		c.applyChatEvent(r, ev)
+	}
+}
+
+// failRun ends a run that can't be recovered with an Error carrying
+// CodeRunLost and the text received before it was lost.
+func (c *Client) failRun(runID, message string) {
+	c.chatMu.Lock()
+	defer c.chatMu.Unlock()
+
+	c.mu.Lock()
+	r, ok := c.runs[runID]
+	var text string
+	if ok {
+		text = r.content
+		delete(c.runs, runID)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	c.log.Warn("run lost", "run", runID, "reason", message)
+	c.emit(Error{RunID: runID, Code: CodeRunLost, Message: message, Text: text})
+}
@@ -0,0 +1,184 @@
+package openclaw_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// countScript streams "w0 w1 ..." in n steps of the given delay, then ends
+// the run with state end.
+func countScript(n int, step time.Duration, end string) gatewaytest.ScriptFunc {
+	return func(string, string) []gatewaytest.Step {
+		var steps []gatewaytest.Step
+		var text string
+		for i := 0; i < n; i++ {
+			text += fmt.Sprintf("w%d ", i)
+			steps = append(steps, gatewaytest.Step{Text: text, Delay: step})
+		}
+		return append(steps, gatewaytest.Step{State: end, Text: text, ErrorCode: "OVERLOADED", ErrorMessage: "model overloaded"})
+	}
+}
+
+// countReply is the whole text of countScript.
+func countReply(n int) string {
+	var text string
+	for i := 0; i < n; i++ {
+		text += fmt.Sprintf("w%d ", i)
+	}
+	return text
+}
+
+// dropDuringRun starts a run, drops the connection after its third delta
+// and returns the text streamed and the event that ended the run.
+// Reconnecting waits about backoff.
+func dropDuringRun(t *testing.T, srv *gatewaytest.Server, backoff time.Duration) (runID, streamed string, end openclaw.RunEvent) {
+	t.Helper()
+	c := srv.Client(t, openclaw.WithBackoff(backoff, backoff))
+	runID = openclaw.NewRunID()
+	if err := c.Send(context.Background(), runID, "main", "count"); err != nil {
+		t.Fatal(err)
+	}
+
+	var deltas int
+	for {
+		ev, ok := gatewaytest.NextEvent(t, c).(openclaw.RunEvent)
+		if !ok || ev.Run() != runID {
+			continue
+		}
+		switch ev := ev.(type) {
+		case openclaw.Delta:
+			streamed += ev.Delta
+			if deltas++; deltas == 3 {
+				srv.Drop()
+			}
+		case openclaw.Replace:
+			streamed = ev.Text
+		case openclaw.Final, openclaw.Error, openclaw.Aborted:
+			return runID, streamed, ev
+		}
+	}
+}
+
+func TestResumeStreamingRun(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(countScript(10, 50*time.Millisecond, "final"))
+
+	_, streamed, end := dropDuringRun(t, srv, 20*time.Millisecond)
+	want := countReply(10)
+	if final, ok := end.(openclaw.Final); !ok || final.Text != want || streamed != want {
+		t.Fatalf("got %+v after streaming %q, want final %q", end, streamed, want)
+	}
+}
+
+func TestResumeEndedRun(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(countScript(10, 10*time.Millisecond, "final"))
+
+	// The run ends before the reconnect; its reply comes from the history
+	_, streamed, end := dropDuringRun(t, srv, 300*time.Millisecond)
+	want := countReply(10)
+	if final, ok := end.(openclaw.Final); !ok || final.Text != want || streamed != want {
+		t.Fatalf("got %+v after streaming %q, want final %q", end, streamed, want)
+	}
+}
+
+func TestResumeLostRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*gatewaytest.Server)
+	}{
+		{"no history", func(srv *gatewaytest.Server) {
+			srv.SetScript(countScript(10, 10*time.Millisecond, "final"))
+			srv.Handle("chat.history", func(json.RawMessage) (interface{}, *openclaw.FrameError) {
+				return map[string]interface{}{"messages": []interface{}{}}, nil
+			})
+		}},
+		{"failed", func(srv *gatewaytest.Server) {
+			srv.SetScript(countScript(10, 10*time.Millisecond, "error"))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := gatewaytest.NewServer()
+			defer srv.Close()
+			tt.setup(srv)
+
+			_, streamed, end := dropDuringRun(t, srv, 300*time.Millisecond)
+			ev, ok := end.(openclaw.Error)
+			if !ok || ev.Code != openclaw.CodeRunLost || ev.Text != streamed || !strings.HasPrefix(countReply(10), streamed) {
+				t.Fatalf("got %+v after streaming %q, want %s with the text so far", end, streamed, openclaw.CodeRunLost)
+			}
+		})
+	}
+}
+
+func TestResumeAbortedRun(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(countScript(10, 10*time.Millisecond, "aborted"))
+
+	_, _, end := dropDuringRun(t, srv, 300*time.Millisecond)
+	if _, ok := end.(openclaw.Aborted); !ok {
+		t.Fatalf("got %+v, want the run aborted", end)
+	}
+}
+
+func TestResumeForgottenRun(t *testing.T) {
+	srv := gatewaytest.NewServer()
+	defer srv.Close()
+	srv.SetScript(countScript(10, 50*time.Millisecond, "final"))
+	c := srv.Client(t, openclaw.WithBackoff(20*time.Millisecond, 20*time.Millisecond))
+	runID := openclaw.NewRunID()
+	if err := c.Send(context.Background(), runID, "main", "count"); err != nil {
+		t.Fatal(err)
+	}
+
+	// After the drop the gateway has forgotten the key and starts the
+	// message again
+	if _, ok := gatewaytest.NextEvent(t, c).(openclaw.Delta); !ok {
+		t.Fatal("no delta")
+	}
+	srv.Handle("chat.send", func(params json.RawMessage) (interface{}, *openclaw.FrameError) {
+		var p struct {
+			IdempotencyKey string `json:"idempotencyKey"`
+		}
+		json.Unmarshal(params, &p)
+		return map[string]string{"runId": p.IdempotencyKey, "status": "started"}, nil
+	})
+	srv.Drop()
+
+	events := gatewaytest.RunEvents(t, c, runID)
+	if ev, ok := events[len(events)-1].(openclaw.Error); !ok || ev.Code != openclaw.CodeRunLost {
+		t.Fatalf("got %+v, want %s", events[len(events)-1], openclaw.CodeRunLost)
+	}
+
+	// The run started again is stopped
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var sends, aborts int
+		for _, frame := range srv.Frames() {
+			switch {
+			case frame.Method == "chat.send":
+				sends++
+			case frame.Method == "chat.abort" && strings.Contains(string(frame.Params), runID):
+				aborts++
+			}
+		}
+		if sends == 2 && aborts == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gateway got %d sends and %d aborts, want the resend aborted", sends, aborts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,83 @@
+package openclaw
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultReorderWindow is how long a chat event that skips a seq is held
+// for the missing ones before it is applied anyway.
+const DefaultReorderWindow = 25 * time.Millisecond
+
+// sequenceLocked returns the chat events of run r that are ready to apply
+// now that ev arrived, in seq order.
+//
+// Each chat event carries the whole text so far, so an event older than
+// the last one applied is dropped: a newer one already covers it. The
+// gateway numbers agent and chat events together, so gaps in seq are
+// normal; an event after a gap is held for up to c.reorder in case the
+// missing ones are only late, then applied regardless. c.mu must be held.
+func (c *Client) sequenceLocked(r *run, ev *chatEvent) []*chatEvent {
+	switch {
+	case ev.Seq == 0:
+		// Not numbered; nothing to order by
+		return []*chatEvent{ev}
+	case ev.Seq <= r.seq:
+		c.log.Debug("dropping stale chat event", "run", ev.RunID, "seq", ev.Seq, "applied", r.seq)
+		return nil
+	case ev.Seq == r.seq+1 || c.reorder <= 0:
+		r.seq = ev.Seq
+		ready := []*chatEvent{ev}
+		// Release what was waiting for this one
+		for next, ok := r.held[r.seq+1]; ok; next, ok = r.held[r.seq+1] {
+			delete(r.held, next.Seq)
+			r.seq = next.Seq
+			ready = append(ready, next)
+		}
+		return ready
+	}
+
+	if r.held == nil {
+		r.held = make(map[int]*chatEvent)
+	}
+	r.held[ev.Seq] = ev
+	if !r.flushing && c.life != nil {
+		r.flushing = true
+		c.wg.Add(1)
+		time.AfterFunc(c.reorder, func() {
+			defer c.wg.Done()
+			c.flushHeld(ev.RunID)
+		})
+	}
+	return nil
+}
+
+// flushHeld applies the chat events of a run that are still held when
+// its reorder window ends, skipping the seqs that never arrived.
+func (c *Client) flushHeld(runID string) {
+	c.chatMu.Lock()
+	defer c.chatMu.Unlock()
+
+	c.mu.Lock()
+	r, ok := c.runs[runID]
+	var ready []*chatEvent
+	if ok {
+		r.flushing = false
+		for _, ev := range r.held {
+			if ev.Seq > r.seq {
+				ready = append(ready, ev)
+			}
+		}
+		r.held = nil
+		sort.Slice(ready, func(i, j int) bool { return ready[i].Seq < ready[j].Seq })
+		if len(ready) > 0 {
+			c.log.Debug("seq gap not filled", "run", runID, "applied", r.seq, "next", ready[0].Seq)
+			r.seq = ready[len(ready)-1].Seq
+		}
+	}
+	c.mu.Unlock()
+
+	for _, ev := range ready {
+		c.applyChatEvent(r, ev)
+	}
+}
@@ -0,0 +1,130 @@
+package openclaw_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+var sequenceWords = []string{"a", "bb", "ccc", " ", "\n", "é", "日本", "x"}
+
+// chatFrame is a chat event as the gateway numbers it.
+type chatFrame struct {
+	seq   int
+	state string
+	text  string
+}
+
+// randomRun returns the chat events of a run whose text mostly grows but
+// is sometimes cut back and rewritten, numbered with gaps as when agent
+// events share the sequence, and with neighbours swapped now and then.
+// rewrites reports whether the text was ever rewritten; swapped whether
+// any events are out of order.
+func randomRun(rng *rand.Rand) (frames []chatFrame, rewrites, swapped bool) {
+	var text string
+	seq := 0
+	for n := 1 + rng.IntN(30); n > 0; n-- {
+		word := sequenceWords[rng.IntN(len(sequenceWords))]
+		if text != "" && rng.IntN(5) == 0 {
+			// Cut at a rune boundary
+			cut := rng.IntN(len(text))
+			for !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + "REV" + word
+			rewrites = true
+		} else {
+			text += word
+		}
+		seq += 1 + rng.IntN(3)
+		frames = append(frames, chatFrame{seq, "delta", text})
+	}
+	frames = append(frames, chatFrame{seq + 1, "final", text})
+
+	if rng.IntN(2) == 0 {
+		for k := rng.IntN(4); k >= 0; k-- {
+			if i := rng.IntN(len(frames)); i+1 < len(frames) {
+				frames[i], frames[i+1] = frames[i+1], frames[i]
+				swapped = true
+			}
+		}
+	}
+	return frames, rewrites, swapped
+}
+
+func chatEvent(runID string, f chatFrame) map[string]interface{} {
+	return map[string]interface{}{
+		"runId": runID,
+		"seq":   f.seq,
+		"state": f.state,
+		"message": map[string]interface{}{
+			"role":    "assistant",
+			"content": []openclaw.ContentPart{{Type: "text", Text: f.text}},
+		},
+	}
+}
+
+// TestSequenceProperties streams random runs and checks that whatever the
+// order and rewrites, the deltas and replaces add up to the text of each
+// event, the final text is the last one sent, and text is only replaced
+// after a rewrite, or after events came out of order with reordering off.
+func TestSequenceProperties(t *testing.T) {
+	iterations := 100
+	if testing.Short() {
+		iterations = 30
+	}
+	for _, window := range []time.Duration{0, openclaw.DefaultReorderWindow} {
+		t.Run(fmt.Sprintf("window=%v", window), func(t *testing.T) {
+			srv := gatewaytest.NewServer()
+			defer srv.Close()
+			srv.SetScript(func(string, string) []gatewaytest.Step { return nil })
+			c := srv.Client(t, openclaw.WithReorderWindow(window))
+
+			for seed := uint64(0); seed < uint64(iterations); seed++ {
+				frames, rewrites, swapped := randomRun(rand.New(rand.NewPCG(seed, 0)))
+				runID := openclaw.NewRunID()
+				if err := c.Send(context.Background(), runID, "main", "x"); err != nil {
+					t.Fatal(err)
+				}
+				for _, f := range frames {
+					srv.Broadcast("chat", chatEvent(runID, f))
+				}
+				want := frames[0].text
+				for _, f := range frames {
+					if f.state == "final" {
+						want = f.text
+					}
+				}
+
+				var text string
+				var replaced bool
+				for _, ev := range gatewaytest.RunEvents(t, c, runID) {
+					switch ev := ev.(type) {
+					case openclaw.Delta:
+						text += ev.Delta
+						if text != ev.Text {
+							t.Fatalf("seed %d: deltas add up to %q, event says %q; frames %q", seed, text, ev.Text, frames)
+						}
+					case openclaw.Replace:
+						text, replaced = ev.Text, true
+					case openclaw.Final:
+						if text != want || ev.Text != want {
+							t.Fatalf("seed %d: streamed %q, final %q, want %q; frames %q", seed, text, ev.Text, want, frames)
+						}
+					default:
+						t.Fatalf("seed %d: got %+v", seed, ev)
+					}
+				}
+				if replaced && !rewrites && (!swapped || window > 0) {
+					t.Fatalf("seed %d: text replaced without a rewrite; frames %q", seed, frames)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package openclaw
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultSessionKey is the gateway's default agent session.
+const DefaultSessionKey = "main"
+
+// SessionInfo describes one gateway session.
+type SessionInfo struct {
+	Key       string
+	Label     string
+	UpdatedAt time.Time
+}
+
+type sessionsPayload struct {
+	Sessions []struct {
+		Key       string `json:"key"`
+		Label     string `json:"label,omitempty"`
+		UpdatedAt int64  `json:"updatedAt,omitempty"` // Unix ms
+	} `json:"sessions"`
+}
+
+// Sessions lists the sessions known to the gateway.
+func (c *Client) Sessions(ctx context.Context) ([]SessionInfo, error) {
+	raw, err := c.Call(ctx, "sessions.list", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	var payload sessionsPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("parse sessions: %w", err)
+	}
+
+	sessions := make([]SessionInfo, 0, len(payload.Sessions))
+	for _, s := range payload.Sessions {
+		info := SessionInfo{Key: s.Key, Label: s.Label}
+		if s.UpdatedAt > 0 {
+			info.UpdatedAt = time.UnixMilli(s.UpdatedAt)
+		}
+		sessions = append(sessions, info)
+	}
+	return sessions, nil
+}
+
+// CreateSession registers a session with the gateway. The gateway also
+// creates sessions implicitly on the first chat.send to a new key.
+func (c *Client) CreateSession(ctx context.Context, key, label string) error {
+	params := map[string]interface{}{
+		"key": key,
+	}
+	if label != "" {
+		params["label"] = label
+	}
+	_, err := c.Call(ctx, "sessions.patch", params)
+	return err
+}
+
+// ResetSession clears the conversation of a session on the gateway.
+func (c *Client) ResetSession(ctx context.Context, key string) error {
+	_, err := c.Call(ctx, "sessions.reset", map[string]interface{}{
+		"key": key,
+	})
+	return err
+}
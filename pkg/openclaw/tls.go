@@ -0,0 +1,101 @@
+package openclaw
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions configures how wss:// connections verify the gateway and
+// authenticate the client.
+type TLSOptions struct {
+	CAFile     string   // PEM bundle trusted in addition to the system roots
+	CertFile   string   // Client certificate for mTLS
+	KeyFile    string   // Key for CertFile
+	ServerName string   // Overrides the name checked against the certificate
+	PinnedSPKI []string // Base64 SHA-256 of an accepted SubjectPublicKeyInfo, optionally "sha256/"-prefixed
+}
+
+// IsZero reports whether no option is set.
+func (o TLSOptions) IsZero() bool {
+	return o.CAFile == "" && o.CertFile == "" && o.KeyFile == "" &&
+		o.ServerName == "" && len(o.PinnedSPKI) == 0
+}
+
+// Config builds the tls.Config for the options. Pins are checked after
+// normal chain verification, so a pinned self-signed certificate also
+// needs its CA in CAFile.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: o.ServerName,
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New("client certificate needs both cert_file and key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(o.PinnedSPKI) > 0 {
+		pins := make(map[string]bool, len(o.PinnedSPKI))
+		for _, pin := range o.PinnedSPKI {
+			pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+			if _, err := base64.StdEncoding.DecodeString(pin); err != nil {
+				return nil, fmt.Errorf("invalid SPKI pin %q: %w", pin, err)
+			}
+			pins[pin] = true
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, cert := range cs.PeerCertificates {
+				if pins[SPKIHash(cert)] {
+					return nil
+				}
+			}
+			return errors.New("gateway certificate does not match any pinned SPKI hash")
+		}
+	}
+
+	return cfg, nil
+}
+
+// SPKIHash returns the base64 SHA-256 of the certificate's public key
+// info, the value used in TLSOptions.PinnedSPKI.
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// WithTLS sets the TLS configuration used for wss:// URLs, e.g. from
+// TLSOptions.Config.
+func WithTLS(cfg *tls.Config) Option {
+	return func(c *Client) error {
+		c.tlsConfig = cfg
+		return nil
+	}
+}
@@ -0,0 +1,163 @@
+package openclaw_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/albxllm/moltstream/internal/gateway/gatewaytest"
+	"github.com/albxllm/moltstream/pkg/openclaw"
+)
+
+// writePEM writes one PEM block to a new file in dir and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCertificate creates a self-signed client certificate and returns
+// it with the paths of its certificate and key files.
+func clientCertificate(t *testing.T) (cert *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "moltstream test client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	return cert, writePEM(t, dir, "client.crt", "CERTIFICATE", der), writePEM(t, dir, "client.key", "PRIVATE KEY", keyDER)
+}
+
+// caFile writes the certificate of a TLS server to a CA bundle file.
+func caFile(t *testing.T, srv *gatewaytest.Server) string {
+	t.Helper()
+	return writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+}
+
+// connectTLS connects a client with opts to srv and returns the error of
+// the dial, or of the connect handshake after it.
+func connectTLS(t *testing.T, srv *gatewaytest.Server, opts openclaw.TLSOptions) error {
+	t.Helper()
+	cfg, err := opts.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := openclaw.New(srv.URL,
+		openclaw.WithIdentityFile(gatewaytest.IdentityFile(t)),
+		openclaw.WithTLS(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Connect(ctx); err != nil {
+		return err
+	}
+	for {
+		select {
+		case ev := <-c.Events():
+			switch ev := ev.(type) {
+			case openclaw.Connected:
+				return nil
+			case openclaw.ConnectionError:
+				return ev.Err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	srv := gatewaytest.NewTLSServer(nil)
+	defer srv.Close()
+	ca := caFile(t, srv)
+	pin := openclaw.SPKIHash(srv.Certificate())
+
+	tests := []struct {
+		name string
+		opts openclaw.TLSOptions
+		err  string // Part of the expected error; empty if it connects
+	}{
+		{"system roots", openclaw.TLSOptions{}, "certificate"},
+		{"ca bundle", openclaw.TLSOptions{CAFile: ca}, ""},
+		{"pin", openclaw.TLSOptions{CAFile: ca, PinnedSPKI: []string{"sha256/" + pin}}, ""},
+		{"pin among others", openclaw.TLSOptions{CAFile: ca, PinnedSPKI: []string{strings.Repeat("A", 43) + "=", pin}}, ""},
+		{"pin mismatch", openclaw.TLSOptions{CAFile: ca, PinnedSPKI: []string{strings.Repeat("A", 43) + "="}}, "pinned SPKI"},
+		// The test certificate is issued for 127.0.0.1 and example.com
+		{"server name", openclaw.TLSOptions{CAFile: ca, ServerName: "example.com"}, ""},
+		{"wrong server name", openclaw.TLSOptions{CAFile: ca, ServerName: "gateway.invalid"}, "gateway.invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := connectTLS(t, srv, tt.opts)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("got %v, want connected", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("got %v, want an error about %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	cert, certFile, keyFile := clientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv := gatewaytest.NewTLSServer(&tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	})
+	defer srv.Close()
+	ca := caFile(t, srv)
+
+	if err := connectTLS(t, srv, openclaw.TLSOptions{CAFile: ca, CertFile: certFile, KeyFile: keyFile}); err != nil {
+		t.Fatalf("with client certificate: %v", err)
+	}
+	if err := connectTLS(t, srv, openclaw.TLSOptions{CAFile: ca}); err == nil {
+		t.Fatal("connected without a client certificate")
+	}
+
+	if _, err := (openclaw.TLSOptions{CertFile: certFile}).Config(); err == nil {
+		t.Error("accepted a client certificate without its key")
+	}
+}
@@ -0,0 +1,138 @@
+package openclaw
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Transport selects how the connection to the gateway is made.
+type Transport struct {
+	// Proxy is an http://, socks5:// or socks5h:// proxy URL. Empty means
+	// use HTTPS_PROXY, then ALL_PROXY, honouring NO_PROXY (for ws:// URLs
+	// HTTP_PROXY comes first); "none" connects directly.
+	Proxy string
+
+	// Socket is a Unix domain socket path. When set the gateway URL only
+	// supplies the scheme, Host header and path, and Proxy is ignored.
+	Socket string
+}
+
+// WithTransport configures proxying or a Unix socket.
+func WithTransport(t Transport) Option {
+	return func(c *Client) error {
+		proxy, err := t.proxyFunc()
+		if err != nil {
+			return err
+		}
+
+		var netDial func(ctx context.Context, network, addr string) (net.Conn, error)
+		if t.Socket != "" {
+			path := t.Socket
+			netDial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			}
+			proxy = nil
+		}
+
+		c.proxy = proxy
+		c.netDial = netDial
+		return nil
+	}
+}
+
+func (t Transport) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	switch t.Proxy {
+	case "":
+		return environmentProxy, nil
+	case "none", "direct":
+		return nil, nil
+	}
+
+	u, err := parseProxyURL(t.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(u), nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (want http, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
+
+// environmentProxy extends http.ProxyFromEnvironment with the ALL_PROXY
+// fallback that curl and most CLI tools honour. gorilla/websocket asks
+// for ws:// URLs as http://, for which ProxyFromEnvironment only looks at
+// HTTP_PROXY; the dial is a CONNECT tunnel either way, so HTTPS_PROXY
+// applies as well.
+func environmentProxy(req *http.Request) (*url.URL, error) {
+	u, err := http.ProxyFromEnvironment(req)
+	if u != nil || err != nil {
+		return u, err
+	}
+
+	raw := getenv("HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy")
+	if raw == "" || noProxy(req.URL.Hostname()) {
+		return nil, nil
+	}
+	if !strings.Contains(raw, "://") {
+		// As ProxyFromEnvironment, take a bare host:port as an HTTP proxy
+		raw = "http://" + raw
+	}
+	return parseProxyURL(raw)
+}
+
+// noProxy reports whether host is excluded by NO_PROXY. Loopback hosts
+// are always excluded, as in http.ProxyFromEnvironment.
+func noProxy(host string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	for _, entry := range strings.Split(getenv("NO_PROXY", "no_proxy"), ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case host == strings.TrimPrefix(entry, "."):
+			return true
+		case strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")):
+			return true
+		}
+	}
+	return false
+}
+
+func getenv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package openclaw
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestEnvironmentProxy(t *testing.T) {
+	// http.ProxyFromEnvironment reads HTTP_PROXY once per process, so
+	// only the variables it doesn't cover can be set here
+	probe := &http.Request{URL: &url.URL{Scheme: "http", Host: "gateway.test"}}
+	if u, _ := http.ProxyFromEnvironment(probe); u != nil {
+		t.Skip("HTTP_PROXY is set in the environment")
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		gateway string
+		want    string
+	}{
+		{"none", nil, "ws://gateway.test:18789", ""},
+		{"https proxy for ws", map[string]string{"HTTPS_PROXY": "http://proxy.test:3128"}, "ws://gateway.test:18789", "http://proxy.test:3128"},
+		{"https proxy for wss", map[string]string{"https_proxy": "http://proxy.test:3128"}, "wss://gateway.test", "http://proxy.test:3128"},
+		{"bare host", map[string]string{"HTTPS_PROXY": "proxy.test:3128"}, "ws://gateway.test:18789", "http://proxy.test:3128"},
+		{"all proxy", map[string]string{"ALL_PROXY": "socks5h://127.0.0.1:1055"}, "ws://gateway.test:18789", "socks5h://127.0.0.1:1055"},
+		{"https before all", map[string]string{"HTTPS_PROXY": "http://proxy.test:3128", "ALL_PROXY": "socks5h://127.0.0.1:1055"}, "ws://gateway.test:18789", "http://proxy.test:3128"},
+		{"no proxy", map[string]string{"HTTPS_PROXY": "http://proxy.test:3128", "NO_PROXY": ".test"}, "ws://gateway.test:18789", ""},
+		{"loopback", map[string]string{"HTTPS_PROXY": "http://proxy.test:3128"}, "ws://127.0.0.1:18789", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			// As gorilla/websocket asks for the proxy of a ws(s):// URL
+			u, err := url.Parse(tt.gateway)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.Scheme = map[string]string{"ws": "http", "wss": "https"}[u.Scheme]
+
+			proxy, err := environmentProxy(&http.Request{URL: u})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if proxy != nil {
+				got = proxy.String()
+			}
+			if got != tt.want {
+				t.Errorf("got proxy %q, want %q", got, tt.want)
+			}
+		})
+	}
+}